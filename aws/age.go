@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudtrail"
+)
+
+// Aged provides an interface for Deletables knowing since when they are
+// in the state that makes them deletable
+type Aged interface {
+	// Since returns the best known time the resource has been unused or
+	// existing since
+	Since(*session.Session) (time.Time, error)
+}
+
+// cloudTrailRetention is how far back CloudTrail keeps the events
+// LookupEvents returns
+const cloudTrailRetention = 90 * 24 * time.Hour
+
+// UnknownAgeError is returned by Since when the age of the resource can't
+// be told, CloudTrail failing to answer
+type UnknownAgeError struct {
+	Why string
+}
+
+func (e UnknownAgeError) Error() string { return "age unknown, " + e.Why }
+
+// lastEvent returns the time of the latest CloudTrail event among the
+// given names about the resource created at the given time, zero if
+// unknown. Without any, the event is older than the retention of
+// CloudTrail and its start is returned, or the creation of the resource
+// if more recent. The age is unknown when CloudTrail can't be queried.
+func lastEvent(s *session.Session, id string, created time.Time, names ...string) (time.Time, error) {
+	wanted := make(map[string]bool, len(names))
+	for _, n := range names {
+		wanted[n] = true
+	}
+	var last *time.Time
+	ctC := cloudtrail.New(s)
+	// Events are returned from the newest.
+	if err := ctC.LookupEventsPages(&cloudtrail.LookupEventsInput{
+		LookupAttributes: []*cloudtrail.LookupAttribute{
+			{
+				AttributeKey:   aws.String(cloudtrail.LookupAttributeKeyResourceName),
+				AttributeValue: aws.String(id),
+			},
+		},
+	}, func(page *cloudtrail.LookupEventsOutput, lastPage bool) bool {
+		for _, e := range page.Events {
+			if wanted[aws.StringValue(e.EventName)] {
+				last = e.EventTime
+				return false
+			}
+		}
+		return true
+	}); err != nil {
+		return time.Time{}, UnknownAgeError{fmt.Sprintf("couldn't look up CloudTrail events of [%s]: %s", id, err)}
+	}
+	if last == nil {
+		retention := time.Now().Add(-cloudTrailRetention)
+		if created.After(retention) {
+			return created, nil
+		}
+		return retention, nil
+	}
+	return *last, nil
+}
+
+// FilterOlderThan splits the list between the resources unused for longer
+// than age and the younger ones. Resources that don't tell their age are
+// kept, those whose age is unknown are excluded.
+func FilterOlderThan(s *session.Session, list []Deletable, age time.Duration) ([]Deletable, []Excluded, error) {
+	kept := make([]Deletable, 0, len(list))
+	var excluded []Excluded
+	for _, d := range list {
+		a, ok := d.(Aged)
+		if !ok {
+			kept = append(kept, d)
+			continue
+		}
+		since, err := a.Since(s)
+		if unknown, ok := err.(UnknownAgeError); ok {
+			excluded = append(excluded, Excluded{d, unknown.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if time.Since(since) < age {
+			excluded = append(excluded, Excluded{d, fmt.Sprintf("younger than %s, since %s",
+				age, since.Format(time.RFC3339))})
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept, excluded, nil
+}
@@ -0,0 +1,285 @@
+package aws
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// LaunchConfiguration is a proxy for the AWS framework struct
+type LaunchConfiguration struct {
+	*autoscaling.LaunchConfiguration
+}
+
+var _ = Deletable(&LaunchConfiguration{})
+var _ = Aged(&LaunchConfiguration{})
+var _ = Referencer(&LaunchConfiguration{})
+
+func describeLaunchConfigurations(s *session.Session) ([]*autoscaling.LaunchConfiguration, error) {
+	var list []*autoscaling.LaunchConfiguration
+	asC := autoscaling.New(s)
+	if err := asC.DescribeLaunchConfigurationsPages(&autoscaling.DescribeLaunchConfigurationsInput{},
+		func(page *autoscaling.DescribeLaunchConfigurationsOutput, lastPage bool) bool {
+			list = append(list, page.LaunchConfigurations...)
+			return true
+		}); err != nil {
+		return nil, fmt.Errorf("Couldn't list launch configurations: %s", err)
+	}
+	return list, nil
+}
+
+func describeAutoScalingGroups(s *session.Session) ([]*autoscaling.Group, error) {
+	var list []*autoscaling.Group
+	asC := autoscaling.New(s)
+	if err := asC.DescribeAutoScalingGroupsPages(&autoscaling.DescribeAutoScalingGroupsInput{},
+		func(page *autoscaling.DescribeAutoScalingGroupsOutput, lastPage bool) bool {
+			list = append(list, page.AutoScalingGroups...)
+			return true
+		}); err != nil {
+		return nil, fmt.Errorf("Couldn't list auto scaling groups: %s", err)
+	}
+	return list, nil
+}
+
+// lookupLaunchConfiguration returns the LaunchConfiguration of the given
+// name
+func lookupLaunchConfiguration(s *session.Session, id string) (Deletable, error) {
+	asC := autoscaling.New(s)
+	res, err := asC.DescribeLaunchConfigurations(&autoscaling.DescribeLaunchConfigurationsInput{
+		LaunchConfigurationNames: []*string{aws.String(id)},
+	})
+	if err != nil || len(res.LaunchConfigurations) == 0 {
+		return nil, lookupError(LaunchConfiguration{}.Type(), id, err)
+	}
+	return LaunchConfiguration{res.LaunchConfigurations[0]}, nil
+}
+
+// ListUnusedLaunchConfigurations returns a list of LaunchConfiguration
+// that are not referenced by any Auto Scaling group.
+func ListUnusedLaunchConfigurations(s *session.Session) ([]LaunchConfiguration, error) {
+	lcs, err := describeLaunchConfigurations(s)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := describeAutoScalingGroups(s)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		used[aws.StringValue(g.LaunchConfigurationName)] = true
+	}
+	list := make([]LaunchConfiguration, 0, len(lcs))
+	for _, lc := range lcs {
+		if !used[aws.StringValue(lc.LaunchConfigurationName)] {
+			list = append(list, LaunchConfiguration{lc})
+		}
+	}
+	return list, nil
+}
+
+// Type returns the Launch Configuration type
+func (lc LaunchConfiguration) Type() string { return "Launch Configuration" }
+
+// ID returns the LaunchConfiguration name
+func (lc LaunchConfiguration) ID() string { return *lc.LaunchConfigurationName }
+
+// Name returns the LaunchConfiguration name
+func (lc LaunchConfiguration) Name() string { return *lc.LaunchConfigurationName }
+
+// Reason explains the LaunchConfiguration is unused
+func (lc LaunchConfiguration) Reason() string { return "not used by any auto scaling group" }
+
+// Since returns when the LaunchConfiguration was created
+func (lc LaunchConfiguration) Since(s *session.Session) (time.Time, error) {
+	return aws.TimeValue(lc.CreatedTime), nil
+}
+
+// References returns the security groups of the LaunchConfiguration
+func (lc LaunchConfiguration) References() map[string][]string {
+	return map[string][]string{"security-group": aws.StringValueSlice(lc.SecurityGroups)}
+}
+
+// Delete the LaunchConfiguration
+func (lc LaunchConfiguration) Delete(s *session.Session) error {
+	asC := autoscaling.New(s)
+	if _, err := asC.DeleteLaunchConfiguration(&autoscaling.DeleteLaunchConfigurationInput{
+		LaunchConfigurationName: lc.LaunchConfigurationName,
+	}); err != nil {
+		return fmt.Errorf("Couldn't delete launch configuration [%s]: %s",
+			*lc.LaunchConfigurationName, err)
+	}
+	return nil
+}
+
+// LaunchTemplateVersion is a proxy for the AWS framework struct
+type LaunchTemplateVersion struct {
+	*ec2.LaunchTemplateVersion
+}
+
+var _ = Deletable(&LaunchTemplateVersion{})
+var _ = Aged(&LaunchTemplateVersion{})
+
+// lookupLaunchTemplateVersion returns the LaunchTemplateVersion of the
+// given template ID and version number
+func lookupLaunchTemplateVersion(s *session.Session, id string) (Deletable, error) {
+	parts := strings.SplitN(id, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Invalid launch template version [%s]", id)
+	}
+	res, err := describeLaunchTemplateVersions(s, &ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateId: aws.String(parts[0]),
+		Versions:         []*string{aws.String(parts[1])},
+	})
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil || len(res) == 0 {
+		return nil, lookupError(LaunchTemplateVersion{}.Type(), id, err)
+	}
+	return LaunchTemplateVersion{res[0]}, nil
+}
+
+// ListOldLaunchTemplateVersions returns a list of LaunchTemplateVersion
+// that are neither the default nor the latest version of their template.
+// Versions pinned by an Auto Scaling group are kept.
+func ListOldLaunchTemplateVersions(s *session.Session) ([]LaunchTemplateVersion, error) {
+	groups, err := describeAutoScalingGroups(s)
+	if err != nil {
+		return nil, err
+	}
+	pinned := make(map[string]bool)
+	for _, g := range groups {
+		if g.LaunchTemplate == nil {
+			continue
+		}
+		pinned[aws.StringValue(g.LaunchTemplate.LaunchTemplateId)+":"+
+			aws.StringValue(g.LaunchTemplate.Version)] = true
+		pinned[aws.StringValue(g.LaunchTemplate.LaunchTemplateName)+":"+
+			aws.StringValue(g.LaunchTemplate.Version)] = true
+	}
+
+	res, err := describeLaunchTemplates(s, &ec2.DescribeLaunchTemplatesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list launch templates: %s", err)
+	}
+	var list []LaunchTemplateVersion
+	for _, lt := range res {
+		vRes, err := describeLaunchTemplateVersions(s, &ec2.DescribeLaunchTemplateVersionsInput{
+			LaunchTemplateId: lt.LaunchTemplateId,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't list versions of launch template [%s]: %s",
+				*lt.LaunchTemplateId, err)
+		}
+		for _, v := range vRes {
+			number := aws.Int64Value(v.VersionNumber)
+			if aws.BoolValue(v.DefaultVersion) || number == aws.Int64Value(lt.LatestVersionNumber) {
+				continue
+			}
+			version := strconv.FormatInt(number, 10)
+			if pinned[*lt.LaunchTemplateId+":"+version] || pinned[*lt.LaunchTemplateName+":"+version] {
+				continue
+			}
+			list = append(list, LaunchTemplateVersion{v})
+		}
+	}
+	return list, nil
+}
+
+// Type returns the Launch Template Version type
+func (v LaunchTemplateVersion) Type() string { return "Launch Template Version" }
+
+// ID returns the LaunchTemplateVersion template ID and version number
+func (v LaunchTemplateVersion) ID() string {
+	return fmt.Sprintf("%s:%d", *v.LaunchTemplateId, *v.VersionNumber)
+}
+
+// Name returns the LaunchTemplateVersion template ID and version number
+func (v LaunchTemplateVersion) Name() string { return v.ID() }
+
+// launchTemplateVersionsTags returns the tags of the listed
+// LaunchTemplateVersion by ID, those of their launch template as versions
+// have none. Launch configurations have no tags either.
+func launchTemplateVersionsTags(s *session.Session, list []Deletable) (map[string]map[string]string, error) {
+	var ids []*string
+	seen := make(map[string]bool)
+	for _, d := range list {
+		if v, ok := d.(LaunchTemplateVersion); ok && !seen[*v.LaunchTemplateId] {
+			ids = append(ids, v.LaunchTemplateId)
+			seen[*v.LaunchTemplateId] = true
+		}
+	}
+	tags := make(map[string]map[string]string, len(list))
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	res, err := describeLaunchTemplates(s, &ec2.DescribeLaunchTemplatesInput{LaunchTemplateIds: ids})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list tags of launch templates: %s", err)
+	}
+	byTemplate := make(map[string]map[string]string, len(res))
+	for _, lt := range res {
+		byTemplate[*lt.LaunchTemplateId] = ec2TagMap(lt.Tags)
+	}
+	for _, d := range list {
+		if v, ok := d.(LaunchTemplateVersion); ok {
+			tags[v.ID()] = byTemplate[*v.LaunchTemplateId]
+		}
+	}
+	return tags, nil
+}
+
+// Reason explains the LaunchTemplateVersion is outdated
+func (v LaunchTemplateVersion) Reason() string { return "neither default nor latest version" }
+
+// Since returns when the LaunchTemplateVersion was created
+func (v LaunchTemplateVersion) Since(s *session.Session) (time.Time, error) {
+	return aws.TimeValue(v.CreateTime), nil
+}
+
+// Delete the LaunchTemplateVersion
+func (v LaunchTemplateVersion) Delete(s *session.Session) error {
+	ec2C := newEC2(s)
+	res, err := ec2C.DeleteLaunchTemplateVersions(&ec2.DeleteLaunchTemplateVersionsInput{
+		LaunchTemplateId: v.LaunchTemplateId,
+		Versions:         []*string{aws.String(strconv.FormatInt(*v.VersionNumber, 10))},
+	})
+	if err != nil {
+		return fmt.Errorf("Couldn't delete launch template version [%s]: %s", v.Name(), err)
+	}
+	for _, e := range res.UnsuccessfullyDeletedLaunchTemplateVersions {
+		if e.ResponseError != nil {
+			return fmt.Errorf("Couldn't delete launch template version [%s]: %s",
+				v.Name(), aws.StringValue(e.ResponseError.Message))
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterKind(Kind{
+		Name:        "launch-configuration",
+		Aliases:     []string{"lc"},
+		Description: "launch configurations not used by any auto scaling group, and outdated launch template versions with --launch-templates",
+		List: func(s *session.Session) ([]Deletable, error) {
+			res, err := ListUnusedLaunchConfigurations(s)
+			if err != nil || !Listing.LaunchTemplates {
+				return deletables(res), err
+			}
+			versions, err := ListOldLaunchTemplateVersions(s)
+			return append(deletables(res), deletables(versions)...), err
+		},
+		Lookups: map[string]LookupFunc{
+			LaunchConfiguration{}.Type():   lookupLaunchConfiguration,
+			LaunchTemplateVersion{}.Type(): lookupLaunchTemplateVersion,
+		},
+		Tags: launchTemplateVersionsTags,
+	})
+}
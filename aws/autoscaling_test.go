@@ -0,0 +1,45 @@
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestExcludeLaunchTemplateVersionsByTag(t *testing.T) {
+	defer useFakeEC2(&fakeEC2{launchTemplates: []*ec2.LaunchTemplate{
+		{LaunchTemplateId: aws.String("lt-prod"), Tags: ec2TagList("env", "prod")},
+		{LaunchTemplateId: aws.String("lt-dev"), Tags: ec2TagList("env", "dev")},
+	}})()
+	version := func(id string, n int64) Deletable {
+		return LaunchTemplateVersion{&ec2.LaunchTemplateVersion{LaunchTemplateId: aws.String(id), VersionNumber: aws.Int64(n)}}
+	}
+	list := []Deletable{
+		LaunchConfiguration{&autoscaling.LaunchConfiguration{LaunchConfigurationName: aws.String("web")}},
+		version("lt-prod", 1),
+		version("lt-dev", 1),
+		version("lt-prod", 2),
+	}
+	kept, excluded, err := Exclusion{Tags: []TagFilter{{Key: "env", Value: "prod"}}}.Filter(nil, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range kept {
+		got = append(got, d.ID())
+	}
+	if want := []string{"web", "lt-dev:1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+	got = nil
+	for _, x := range excluded {
+		got = append(got, x.ID()+": "+x.Why)
+	}
+	want := []string{"lt-prod:1: excluded by tag env=prod", "lt-prod:2: excluded by tag env=prod"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("excluded %q, want %q", got, want)
+	}
+}
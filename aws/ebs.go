@@ -0,0 +1,203 @@
+package aws
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// EBSVolume is a proxy for the AWS framework struct
+type EBSVolume struct {
+	*ec2.Volume
+}
+
+var _ = Deletable(&EBSVolume{})
+var _ = Sweetener(&EBSVolume{})
+var _ = Aged(&EBSVolume{})
+var _ = Tagged(&EBSVolume{})
+var _ = Recoverable(&EBSVolume{})
+
+// ListAvailableEBS returns a list of Available EBSVolume
+func ListAvailableEBS(s *session.Session) ([]EBSVolume, error) {
+	res, err := describeVolumes(s, &ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("status"),
+				Values: []*string{aws.String("available")},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list EBS volumes: %s", err)
+	}
+	list := make([]EBSVolume, 0, len(res))
+	for _, ni := range res {
+		list = append(list, EBSVolume{ni})
+	}
+	return list, nil
+}
+
+// lookupVolume returns the EBSVolume of the given ID
+func lookupVolume(s *session.Session, id string) (Deletable, error) {
+	res, err := describeVolumes(s, &ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{idFilter("volume-id", id)},
+	})
+	if err != nil || len(res) == 0 {
+		return nil, lookupError(EBSVolume{}.Type(), id, err)
+	}
+	return EBSVolume{res[0]}, nil
+}
+
+// Type returns the EBS type
+func (v EBSVolume) Type() string { return "EBS" }
+
+// ID returns the Volume ID
+func (v EBSVolume) ID() string { return *v.VolumeId }
+
+// Name returns the Volume ID
+func (v EBSVolume) Name() string { return *v.VolumeId }
+
+// Reason explains the EBSVolume is available
+func (v EBSVolume) Reason() string { return "available" }
+
+// Since returns when the EBSVolume was detached according to CloudTrail,
+// or created if it was never detached since
+func (v EBSVolume) Since(s *session.Session) (time.Time, error) {
+	created := aws.TimeValue(v.CreateTime)
+	detached, err := lastEvent(s, *v.VolumeId, created, "DetachVolume")
+	if err != nil {
+		return time.Time{}, err
+	}
+	if created.After(detached) {
+		return created, nil
+	}
+	return detached, nil
+}
+
+// TagMap returns the tags of the EBSVolume
+func (v EBSVolume) TagMap() map[string]string { return ec2TagMap(v.Tags) }
+
+// SweetenSteps describes the snapshot of the EBSVolume
+func (v EBSVolume) SweetenSteps() []string {
+	return []string{"snapshot " + *v.VolumeId}
+}
+
+// Delete the EBSVolume
+func (v EBSVolume) Delete(s *session.Session) error {
+	ec2C := newEC2(s)
+	if _, err := ec2C.DeleteVolume(&ec2.DeleteVolumeInput{
+		VolumeId: v.VolumeId,
+	}); err != nil {
+		return fmt.Errorf("Couldn't delete EBS volume [%s]: %s", *v.VolumeId, err)
+	}
+	return nil
+}
+
+// Sweeten creates a snapshot for the volume and waits for it to finish
+// before the deletion of the EBSVolume, returning the snapshot ID
+func (v EBSVolume) Sweeten(ctx aws.Context, s *session.Session, out io.Writer) (string, error) {
+	description := *v.VolumeId
+	if name := ec2TagValue(v.Tags, "Name"); name != "" {
+		description += "_" + name
+	}
+	tags := ec2UserTags(v.Tags)
+	ec2C := newEC2(s)
+	res, err := ec2C.CreateSnapshot(&ec2.CreateSnapshotInput{
+		Description: aws.String(description),
+		VolumeId:    v.VolumeId,
+		TagSpecifications: []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String(ec2.ResourceTypeSnapshot),
+				Tags:         tags,
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("Couldn't snapshot EBS volume [%s]: %s", *v.VolumeId, err)
+	}
+	snap := &Snapshot{res}
+	if err := snap.Wait(ctx, s, out); err != nil {
+		return "", err
+	}
+	return *res.SnapshotId, nil
+}
+
+// Recovery returns the snapshot of the EBSVolume taken by Sweeten along
+// with its settings. Without snapshot the volume can't be recreated and
+// no Recovery is returned.
+func (v EBSVolume) Recovery(s *session.Session, artifact string) (*Recovery, error) {
+	if artifact == "" {
+		return nil, nil
+	}
+	return &Recovery{Volume: &VolumeRecovery{
+		SnapshotID:       artifact,
+		AvailabilityZone: aws.StringValue(v.AvailabilityZone),
+		VolumeType:       aws.StringValue(v.VolumeType),
+		Size:             aws.Int64Value(v.Size),
+		Iops:             aws.Int64Value(v.Iops),
+		Tags:             omitTags(ec2TagMap(v.Tags), awsTagKeys),
+	}}, nil
+}
+
+// VolumeRecovery holds the snapshot of an EBSVolume and its settings
+type VolumeRecovery struct {
+	SnapshotID       string            `json:"snapshotId"`
+	AvailabilityZone string            `json:"availabilityZone"`
+	VolumeType       string            `json:"volumeType"`
+	Size             int64             `json:"size"`
+	Iops             int64             `json:"iops,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"`
+}
+
+// sizedIopsVolumeTypes are the volume types whose IOPS follow from their
+// size, CreateVolume rejects the IOPS of those
+var sizedIopsVolumeTypes = map[string]bool{
+	ec2.VolumeTypeStandard: true,
+	ec2.VolumeTypeGp2:      true,
+	ec2.VolumeTypeSc1:      true,
+	ec2.VolumeTypeSt1:      true,
+}
+
+func (r *VolumeRecovery) restore(s *session.Session) (string, error) {
+	input := &ec2.CreateVolumeInput{
+		SnapshotId:       aws.String(r.SnapshotID),
+		AvailabilityZone: aws.String(r.AvailabilityZone),
+		VolumeType:       aws.String(r.VolumeType),
+		Size:             aws.Int64(r.Size),
+	}
+	if r.Iops > 0 && !sizedIopsVolumeTypes[r.VolumeType] {
+		input.Iops = aws.Int64(r.Iops)
+	}
+	if len(r.Tags) > 0 {
+		input.TagSpecifications = []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String(ec2.ResourceTypeVolume),
+				Tags:         ec2Tags(r.Tags),
+			},
+		}
+	}
+	ec2C := newEC2(s)
+	res, err := ec2C.CreateVolume(input)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't create EBS volume from snapshot [%s]: %s", r.SnapshotID, err)
+	}
+	return *res.VolumeId, nil
+}
+
+func init() {
+	RegisterKind(Kind{
+		Name:        "ebs",
+		Aliases:     []string{"volume"},
+		Description: "available EBS volumes, snapshotted first",
+		List: func(s *session.Session) ([]Deletable, error) {
+			res, err := ListAvailableEBS(s)
+			return deletables(res), err
+		},
+		Lookups: map[string]LookupFunc{EBSVolume{}.Type(): lookupVolume},
+		Sweeten: true,
+	})
+}
@@ -1,278 +1,155 @@
 package aws
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"io"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/tj/go-progress"
 )
 
 // Deletable provides an interface for any EC2 resource that can be deleted
 type Deletable interface {
 	Type() string
+	// ID identifies the resource among the resources of its Type
+	ID() string
 	Name() string
+	// Reason explains why the resource is to be deleted
+	Reason() string
 	Delete(*session.Session) error
 }
 
+// newEC2 returns the EC2 client of the session, tests replace it with a
+// fake
+var newEC2 = func(s *session.Session) ec2iface.EC2API { return ec2.New(s) }
+
 // Sweetener provides an interface to do preventive cleaning before deleting
 type Sweetener interface {
-	Sweeten(*session.Session) error
+	// SweetenSteps describes what Sweeten does for the resource
+	SweetenSteps() []string
+	// Sweeten stops waiting and fails once ctx is done. Its progress is
+	// written to the io.Writer. It returns the ID of what it left to
+	// recreate the resource from, if anything.
+	Sweeten(aws.Context, *session.Session, io.Writer) (string, error)
 }
 
-// EC2Instance is a proxy for the AWS framework struct
-type EC2Instance struct {
-	*ec2.Instance
+// Snapshot is a proxy for the AWS framework struct
+type Snapshot struct {
+	*ec2.Snapshot
 }
 
-var _ = Deletable(&EC2Instance{})
-
-// ListInstances returns the list of EC2Instance for the specific ids provided
-func ListInstances(s *session.Session, ids []*string) ([]EC2Instance, error) {
-	ec2C := ec2.New(s)
-	res, err := ec2C.DescribeInstances(&ec2.DescribeInstancesInput{
-		InstanceIds: ids,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("Couldn't list instances: %s", err)
-	}
-	var list []EC2Instance
-	for i := range res.Reservations {
-		for _, is := range res.Reservations[i].Instances {
-			list = append(list, EC2Instance{is})
-		}
-	}
-	return list, nil
+// WaitConfig configures how Snapshot.Wait polls the state of snapshots
+type WaitConfig struct {
+	Delay time.Duration
+	// MaxAttempts of 0 polls until the context of the wait is done
+	MaxAttempts int
+	// ShowProgress toggles the progress bar, bars of snapshots awaited
+	// at the same time would garble each other
+	ShowProgress bool
 }
 
-// Type returns the EC2 type
-func (e EC2Instance) Type() string { return "EC2" }
+// Waiting is the WaitConfig of Snapshot.Wait
+var Waiting = WaitConfig{Delay: 15 * time.Second, ShowProgress: true}
 
-// Name returns the EC2 Instance name
-func (e EC2Instance) Name() string {
-	for i := range e.Tags {
-		if e.Tags[i].Key == aws.String("Name") {
-			return *e.Tags[i].Value
+// Wait for the Snapshot to finish before doing anything else. The wait
+// fails when the snapshot enters the error state, when Waiting.MaxAttempts
+// is reached or once ctx is done. Its progress is written to out.
+func (snap *Snapshot) Wait(ctx aws.Context, s *session.Session, out io.Writer) error {
+	ec2C := newEC2(s)
+	fmt.Fprintf(out, "Starting to monitor snapshot [%s]. This can take a few minutes...\n",
+		*snap.SnapshotId)
+	bar := progress.NewInt(100)
+	bar.Text(*snap.SnapshotId)
+	var lastPercent string
+	watch := func(r *request.Request) {
+		if r.Error != nil {
+			return
+		}
+		for _, ws := range r.Data.(*ec2.DescribeSnapshotsOutput).Snapshots {
+			percent := aws.StringValue(ws.Progress)
+			if Waiting.ShowProgress && percent != "" && lastPercent != percent {
+				lastPercent = percent
+				percentInt, _ := strconv.Atoi(strings.TrimSuffix(percent, "%"))
+				bar.ValueInt(percentInt)
+				bar.WriteTo(out)
+			}
 		}
 	}
-	return *e.InstanceId
-}
-
-// Delete the LoadBalancer
-func (e EC2Instance) Delete(s *session.Session) error {
-	ec2C := ec2.New(s)
-	if _, err := ec2C.TerminateInstances(&ec2.TerminateInstancesInput{
-		InstanceIds: []*string{e.InstanceId},
-	}); err != nil {
-		return fmt.Errorf("Couldn't delete EC2 instance [%s]: %s", e.Name(), err)
-	}
-	return nil
-}
-
-// LoadBalancer is a proxy for the AWS framework struct
-type LoadBalancer struct {
-	*elb.LoadBalancerDescription
-}
-
-var _ = Deletable(&LoadBalancer{})
-
-// ListInactiveLoadBalancers returns a list of LoadBalancer that have no
-// EC2Instance attached to it.
-func ListInactiveLoadBalancers(s *session.Session) ([]LoadBalancer, error) {
-	elbC := elb.New(s)
-	res, err := elbC.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{})
+	input := &ec2.DescribeSnapshotsInput{SnapshotIds: []*string{snap.SnapshotId}}
+	err := ec2C.WaitUntilSnapshotCompletedWithContext(ctx, input,
+		request.WithWaiterDelay(request.ConstantWaiterDelay(Waiting.Delay)),
+		request.WithWaiterMaxAttempts(Waiting.MaxAttempts),
+		snapshotAcceptors,
+		request.WithWaiterRequestOptions(func(r *request.Request) {
+			r.Handlers.Complete.PushBack(watch)
+		}),
+	)
 	if err != nil {
-		return nil, fmt.Errorf("Couldn't list load balancers: %s", err)
-	}
-	list := make([]LoadBalancer, 0, len(res.LoadBalancerDescriptions))
-	for _, lb := range res.LoadBalancerDescriptions {
-		if len(lb.Instances) == 0 {
-			list = append(list, LoadBalancer{lb})
+		// The waiter doesn't tell why the snapshot failed, its state does.
+		if res, derr := ec2C.DescribeSnapshotsWithContext(ctx, input); derr == nil {
+			for _, ws := range res.Snapshots {
+				if aws.StringValue(ws.State) == ec2.SnapshotStateError {
+					return fmt.Errorf("Snapshot [%s] failed: %s", *snap.SnapshotId, aws.StringValue(ws.StateMessage))
+				}
+			}
 		}
+		return waitError(ctx, "snapshot", *snap.SnapshotId, err)
 	}
-	return list, nil
-}
-
-// Type returns the ELB type
-func (lb LoadBalancer) Type() string { return "ELB" }
-
-// Name returns the LoadBalancer name
-func (lb LoadBalancer) Name() string { return *lb.LoadBalancerName }
-
-// Delete the LoadBalancer
-func (lb LoadBalancer) Delete(s *session.Session) error {
-	elbC := elb.New(s)
-	if _, err := elbC.DeleteLoadBalancer(&elb.DeleteLoadBalancerInput{
-		LoadBalancerName: lb.LoadBalancerName,
-	}); err != nil {
-		return fmt.Errorf("Couldn't delete load balancer [%s]: %s", *lb.LoadBalancerName, err)
-	}
+	fmt.Fprintf(out, "\nSnapshot completed\n")
 	return nil
 }
 
-// NetworkInterface is a proxy for the AWS framework struct
-type NetworkInterface struct {
-	*ec2.NetworkInterface
-}
-
-var _ = Deletable(&NetworkInterface{})
-
-// ListUnattachedNetworkInterfaces returns a list of NetworkInterface
-// that are currently not attached to an EC2Instance
-func ListUnattachedNetworkInterfaces(s *session.Session) ([]NetworkInterface, error) {
-	ec2C := ec2.New(s)
-	res, err := ec2C.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{
-		Filters: []*ec2.Filter{
-			{
-				Name:   aws.String("status"),
-				Values: []*string{aws.String("available")},
-			},
+// snapshotAcceptors makes the wait of a snapshot fail once the snapshot is
+// in error, the SDK waiter only knows about the completed state. A
+// snapshot just created may not be found yet, the wait goes on then.
+func snapshotAcceptors(w *request.Waiter) {
+	w.Acceptors = append(w.Acceptors,
+		request.WaiterAcceptor{
+			State:    request.FailureWaiterState,
+			Matcher:  request.PathAnyWaiterMatch,
+			Argument: "Snapshots[].State",
+			Expected: ec2.SnapshotStateError,
 		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("Couldn't list network interfaces: %s", err)
-	}
-	list := make([]NetworkInterface, 0, len(res.NetworkInterfaces))
-	for _, ni := range res.NetworkInterfaces {
-		list = append(list, NetworkInterface{ni})
-	}
-	return list, nil
-}
-
-// Type returns the Network Interface type
-func (ni NetworkInterface) Type() string { return "Network Interface" }
-
-// Name returns the NetworkInterface ID
-func (ni NetworkInterface) Name() string { return *ni.NetworkInterfaceId }
-
-// Delete the NetworkInterface
-func (ni NetworkInterface) Delete(s *session.Session) error {
-	ec2C := ec2.New(s)
-	if _, err := ec2C.DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceInput{
-		NetworkInterfaceId: ni.NetworkInterfaceId,
-	}); err != nil {
-		return fmt.Errorf("Couldn't delete network interface [%s]: %s", *ni.NetworkInterfaceId, err)
-	}
-	return nil
-}
-
-// EBSVolume is a proxy for the AWS framework struct
-type EBSVolume struct {
-	*ec2.Volume
-}
-
-var _ = Deletable(&EBSVolume{})
-var _ = Sweetener(&EBSVolume{})
-
-// ListAvailableEBS returns a list of Available EBSVolume
-func ListAvailableEBS(s *session.Session) ([]EBSVolume, error) {
-	ec2C := ec2.New(s)
-	res, err := ec2C.DescribeVolumes(&ec2.DescribeVolumesInput{
-		Filters: []*ec2.Filter{
-			{
-				Name:   aws.String("status"),
-				Values: []*string{aws.String("available")},
-			},
+		request.WaiterAcceptor{
+			State:    request.RetryWaiterState,
+			Matcher:  request.ErrorWaiterMatch,
+			Expected: "InvalidSnapshot.NotFound",
 		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("Couldn't list EBS volumes: %s", err)
-	}
-	list := make([]EBSVolume, 0, len(res.Volumes))
-	for _, ni := range res.Volumes {
-		list = append(list, EBSVolume{ni})
-	}
-	return list, nil
+	)
 }
 
-// Type returns the EBS type
-func (v EBSVolume) Type() string { return "EBS" }
-
-// Name returns the Volume ID
-func (v EBSVolume) Name() string { return *v.VolumeId }
-
-// Delete the EBSVolume
-func (v EBSVolume) Delete(s *session.Session) error {
-	ec2C := ec2.New(s)
-	if _, err := ec2C.DeleteVolume(&ec2.DeleteVolumeInput{
-		VolumeId: v.VolumeId,
-	}); err != nil {
-		return fmt.Errorf("Couldn't delete EBS volume [%s]: %s", *v.VolumeId, err)
+// waitError explains why the wait for a resource failed, ctx being the
+// context of the wait
+func waitError(ctx aws.Context, what, id string, err error) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return fmt.Errorf("Timed out waiting for %s [%s]", what, id)
+	case context.Canceled:
+		return fmt.Errorf("Interrupted while waiting for %s [%s]", what, id)
 	}
-	return nil
+	return fmt.Errorf("Couldn't wait for %s [%s]: %s", what, id, err)
 }
 
-// Sweeten creates a snapshot for the volume and waits for it to finish
-// before the deletion of the EBSVolume
-func (v EBSVolume) Sweeten(s *session.Session) error {
-	name := v.VolumeId
-	tags := make([]*ec2.Tag, 0, len(v.Tags))
-	for i := range v.Tags {
-		if v.Tags[i].Key == aws.String("Name") {
-			name = aws.String(*name + "_" + *v.Tags[i].Value)
-		}
-		tags = append(tags, v.Tags[i])
-	}
-	ec2C := ec2.New(s)
-	res, err := ec2C.CreateSnapshot(&ec2.CreateSnapshotInput{
-		Description: name,
-		VolumeId:    v.VolumeId,
-		TagSpecifications: []*ec2.TagSpecification{
-			{
-				ResourceType: aws.String(ec2.ResourceTypeSnapshot),
-				Tags:         tags,
-			},
-		},
-	})
+// ListRegions returns the names of the regions enabled for the account
+func ListRegions(s *session.Session) ([]string, error) {
+	ec2C := newEC2(s)
+	res, err := ec2C.DescribeRegions(&ec2.DescribeRegionsInput{})
 	if err != nil {
-		return fmt.Errorf("Couldn't snapshot EBS volume [%s]: %s", *v.VolumeId, err)
+		return nil, fmt.Errorf("Couldn't list regions: %s", err)
 	}
-	snap := &Snapshot{res}
-	return snap.Wait(s)
-}
-
-// Snapshot is a proxy for the AWS framework struct
-type Snapshot struct {
-	*ec2.Snapshot
-}
-
-// Wait for the Snapshot to finish before doing anything else
-func (snap *Snapshot) Wait(s *session.Session) error {
-	ec2C := ec2.New(s)
-	fmt.Printf("Starting to monitor snapshot [%s]. This can take a few minutes...\n",
-		*snap.SnapshotId)
-	bar := progress.NewInt(100)
-	bar.Text(*snap.SnapshotId)
-	ticker := time.NewTicker(1 * time.Minute)
-	var lastPercent string
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			res, err := ec2C.DescribeSnapshots(&ec2.DescribeSnapshotsInput{
-				SnapshotIds: []*string{snap.SnapshotId},
-			})
-			if err != nil {
-				return fmt.Errorf("Couldn't wait for snapshot [%s]: %s",
-					*snap.SnapshotId, err)
-			}
-			for _, ws := range res.Snapshots {
-				if *ws.State == ec2.SnapshotStateCompleted {
-					fmt.Printf("\nSnapshot completed\n")
-					return nil
-				}
-				if lastPercent != *ws.Progress {
-					lastPercent = *ws.Progress
-					percentInt, _ := strconv.Atoi(lastPercent[:len(lastPercent)-1])
-					bar.ValueInt(percentInt)
-					bar.WriteTo(os.Stdout)
-				}
-			}
-		}
+	list := make([]string, 0, len(res.Regions))
+	for _, r := range res.Regions {
+		list = append(list, *r.RegionName)
 	}
+	sort.Strings(list)
+	return list, nil
 }
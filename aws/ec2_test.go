@@ -0,0 +1,241 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// fakeEC2 answers the calls of the tests, the others panic. The waiters
+// run against an SDK client whose requests the fake answers, see sdk.
+type fakeEC2 struct {
+	ec2iface.EC2API
+	// snapshots are the states returned by the successive calls to
+	// DescribeSnapshotsWithContext, the last one repeating, nil for a
+	// snapshot not found yet
+	snapshots []*ec2.Snapshot
+	calls     int
+	// securityGroupPages are the pages of DescribeSecurityGroups, the
+	// token of each page being its index
+	securityGroupPages [][]*ec2.SecurityGroup
+	tokens             []string
+	// instancePages are the pages of DescribeInstances, one reservation
+	// each, the token of each page being its index too
+	instancePages [][]*ec2.Instance
+	// terminated are the instances waited for, terminateErr the error of
+	// the wait
+	terminated   []string
+	terminateErr error
+	// launchTemplates are the templates DescribeLaunchTemplates filters
+	// by ID
+	launchTemplates []*ec2.LaunchTemplate
+}
+
+func (f *fakeEC2) DescribeSnapshotsWithContext(ctx aws.Context, input *ec2.DescribeSnapshotsInput, opts ...request.Option) (*ec2.DescribeSnapshotsOutput, error) {
+	snap := f.snapshots[len(f.snapshots)-1]
+	if f.calls < len(f.snapshots) {
+		snap = f.snapshots[f.calls]
+	}
+	f.calls++
+	if snap == nil {
+		return nil, awserr.New("InvalidSnapshot.NotFound", "The snapshot does not exist.", nil)
+	}
+	return &ec2.DescribeSnapshotsOutput{Snapshots: []*ec2.Snapshot{snap}}, nil
+}
+
+func (f *fakeEC2) WaitUntilSnapshotCompletedWithContext(ctx aws.Context, input *ec2.DescribeSnapshotsInput, opts ...request.WaiterOption) error {
+	return f.sdk().WaitUntilSnapshotCompletedWithContext(ctx, input, opts...)
+}
+
+// sdk returns an SDK client whose requests are answered by the fake
+// instead of being sent
+func (f *fakeEC2) sdk() *ec2.EC2 {
+	c := ec2.New(session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.AnonymousCredentials,
+	})))
+	c.Handlers.Clear()
+	c.Handlers.Send.PushBack(func(r *request.Request) {
+		var out interface{}
+		switch input := r.Params.(type) {
+		case *ec2.DescribeSnapshotsInput:
+			out, r.Error = f.DescribeSnapshotsWithContext(r.Context(), input)
+		case *ec2.DescribeInstancesInput:
+			out, r.Error = f.DescribeInstances(input)
+		default:
+			r.Error = fmt.Errorf("unexpected call to %s", r.Operation.Name)
+		}
+		if r.Error == nil {
+			reflect.ValueOf(r.Data).Elem().Set(reflect.ValueOf(out).Elem())
+		}
+	})
+	return c
+}
+
+func (f *fakeEC2) DescribeSecurityGroups(input *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	token := aws.StringValue(input.NextToken)
+	f.tokens = append(f.tokens, token)
+	i := 0
+	if token != "" {
+		i, _ = strconv.Atoi(token)
+	}
+	res := &ec2.DescribeSecurityGroupsOutput{SecurityGroups: f.securityGroupPages[i]}
+	if i+1 < len(f.securityGroupPages) {
+		res.NextToken = aws.String(strconv.Itoa(i + 1))
+	}
+	return res, nil
+}
+
+func (f *fakeEC2) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	token := aws.StringValue(input.NextToken)
+	f.tokens = append(f.tokens, token)
+	i := 0
+	if token != "" {
+		i, _ = strconv.Atoi(token)
+	}
+	res := &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: f.instancePages[i]}}}
+	if i+1 < len(f.instancePages) {
+		res.NextToken = aws.String(strconv.Itoa(i + 1))
+	}
+	return res, nil
+}
+
+func (f *fakeEC2) DescribeInstancesPages(input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	return f.sdk().DescribeInstancesPages(input, fn)
+}
+
+func (f *fakeEC2) DescribeLaunchTemplates(input *ec2.DescribeLaunchTemplatesInput) (*ec2.DescribeLaunchTemplatesOutput, error) {
+	res := &ec2.DescribeLaunchTemplatesOutput{}
+	for _, lt := range f.launchTemplates {
+		for _, id := range input.LaunchTemplateIds {
+			if *id == *lt.LaunchTemplateId {
+				res.LaunchTemplates = append(res.LaunchTemplates, lt)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeEC2) WaitUntilInstanceTerminatedWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.WaiterOption) error {
+	f.terminated = aws.StringValueSlice(input.InstanceIds)
+	return f.terminateErr
+}
+
+// useFakeEC2 makes newEC2 return the fake, until the returned func
+// restores it
+func useFakeEC2(f ec2iface.EC2API) func() {
+	prev := newEC2
+	newEC2 = func(s *session.Session) ec2iface.EC2API { return f }
+	return func() { newEC2 = prev }
+}
+
+// useWaiting sets Waiting, until the returned func restores it
+func useWaiting(c WaitConfig) func() {
+	prev := Waiting
+	Waiting = c
+	return func() { Waiting = prev }
+}
+
+func snapshotState(state, progress, message string) *ec2.Snapshot {
+	return &ec2.Snapshot{
+		SnapshotId:   aws.String("snap-1"),
+		State:        aws.String(state),
+		Progress:     aws.String(progress),
+		StateMessage: aws.String(message),
+	}
+}
+
+func TestSnapshotWait(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		snapshots   []*ec2.Snapshot
+		maxAttempts int
+		calls       int
+		err         string
+	}{
+		{
+			name: "pending then completed",
+			snapshots: []*ec2.Snapshot{
+				snapshotState(ec2.SnapshotStatePending, "0%", ""),
+				snapshotState(ec2.SnapshotStatePending, "50%", ""),
+				snapshotState(ec2.SnapshotStateCompleted, "100%", ""),
+			},
+			calls: 3,
+		},
+		{
+			name: "pending then error",
+			snapshots: []*ec2.Snapshot{
+				snapshotState(ec2.SnapshotStatePending, "10%", ""),
+				snapshotState(ec2.SnapshotStateError, "10%", "volume gone"),
+			},
+			// The failed snapshot is described once more for its message.
+			calls: 3,
+			err:   "Snapshot [snap-1] failed: volume gone",
+		},
+		{
+			name: "not found yet then completed",
+			snapshots: []*ec2.Snapshot{
+				nil,
+				snapshotState(ec2.SnapshotStatePending, "0%", ""),
+				snapshotState(ec2.SnapshotStateCompleted, "100%", ""),
+			},
+			calls: 3,
+		},
+		{
+			name: "max attempts",
+			snapshots: []*ec2.Snapshot{
+				snapshotState(ec2.SnapshotStatePending, "10%", ""),
+			},
+			maxAttempts: 4,
+			calls:       5,
+			err:         "Couldn't wait for snapshot [snap-1]: ResourceNotReady: exceeded wait attempts",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeEC2{snapshots: tc.snapshots}
+			defer useFakeEC2(fake)()
+			defer useWaiting(WaitConfig{Delay: time.Millisecond, MaxAttempts: tc.maxAttempts, ShowProgress: true})()
+			var out bytes.Buffer
+			snap := &Snapshot{&ec2.Snapshot{SnapshotId: aws.String("snap-1")}}
+			err := snap.Wait(context.Background(), nil, &out)
+			if tc.err == "" && err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+			if tc.err != "" && (err == nil || err.Error() != tc.err) {
+				t.Fatalf("err = %v, want %s", err, tc.err)
+			}
+			if fake.calls != tc.calls {
+				t.Errorf("%d calls, want %d", fake.calls, tc.calls)
+			}
+			if tc.err == "" && !strings.Contains(out.String(), "Snapshot completed") {
+				t.Errorf("output = %q, want the completion", out.String())
+			}
+		})
+	}
+}
+
+func TestSnapshotWaitCanceled(t *testing.T) {
+	fake := &fakeEC2{snapshots: []*ec2.Snapshot{snapshotState(ec2.SnapshotStatePending, "10%", "")}}
+	defer useFakeEC2(fake)()
+	defer useWaiting(WaitConfig{Delay: time.Hour})()
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+	snap := &Snapshot{&ec2.Snapshot{SnapshotId: aws.String("snap-1")}}
+	err := snap.Wait(ctx, nil, &bytes.Buffer{})
+	if want := "Interrupted while waiting for snapshot [snap-1]"; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %s", err, want)
+	}
+}
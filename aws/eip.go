@@ -0,0 +1,159 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// ElasticIP is a proxy for the AWS framework struct
+type ElasticIP struct {
+	*ec2.Address
+}
+
+var _ = Deletable(&ElasticIP{})
+var _ = Aged(&ElasticIP{})
+var _ = Tagged(&ElasticIP{})
+var _ = Recoverable(&ElasticIP{})
+
+// ListUnassociatedAddresses returns a list of ElasticIP that are not
+// associated to any instance or network interface
+func ListUnassociatedAddresses(s *session.Session) ([]ElasticIP, error) {
+	ec2C := newEC2(s)
+	res, err := ec2C.DescribeAddresses(&ec2.DescribeAddressesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list elastic IPs: %s", err)
+	}
+	list := make([]ElasticIP, 0, len(res.Addresses))
+	for _, a := range res.Addresses {
+		if a.AssociationId == nil && a.InstanceId == nil && a.NetworkInterfaceId == nil {
+			list = append(list, ElasticIP{a})
+		}
+	}
+	return list, nil
+}
+
+// lookupAddress returns the ElasticIP of the given allocation ID, or
+// public IP in EC2-Classic
+func lookupAddress(s *session.Session, id string) (Deletable, error) {
+	filter := idFilter("allocation-id", id)
+	if !strings.HasPrefix(id, "eipalloc-") {
+		filter = idFilter("public-ip", id)
+	}
+	ec2C := newEC2(s)
+	res, err := ec2C.DescribeAddresses(&ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{filter},
+	})
+	if err != nil || len(res.Addresses) == 0 {
+		return nil, lookupError(ElasticIP{}.Type(), id, err)
+	}
+	return ElasticIP{res.Addresses[0]}, nil
+}
+
+// Type returns the Elastic IP type
+func (eip ElasticIP) Type() string { return "EIP" }
+
+// ID returns the allocation ID of the ElasticIP, or its public IP in EC2-Classic
+func (eip ElasticIP) ID() string {
+	if eip.isVPC() {
+		return *eip.AllocationId
+	}
+	return *eip.PublicIp
+}
+
+// Name returns the public IP of the ElasticIP
+func (eip ElasticIP) Name() string { return *eip.PublicIp }
+
+// Reason explains the ElasticIP is unassociated
+func (eip ElasticIP) Reason() string { return "not associated" }
+
+// Since returns when the ElasticIP was disassociated according to
+// CloudTrail, or allocated if it was never associated
+func (eip ElasticIP) Since(s *session.Session) (time.Time, error) {
+	return lastEvent(s, eip.ID(), time.Time{}, "DisassociateAddress", "AllocateAddress")
+}
+
+// TagMap returns the tags of the ElasticIP
+func (eip ElasticIP) TagMap() map[string]string { return ec2TagMap(eip.Tags) }
+
+// isVPC tells if the ElasticIP is allocated for use in a VPC or in EC2-Classic
+func (eip ElasticIP) isVPC() bool {
+	return aws.StringValue(eip.Domain) == ec2.DomainTypeVpc
+}
+
+// Delete releases the ElasticIP
+func (eip ElasticIP) Delete(s *session.Session) error {
+	input := &ec2.ReleaseAddressInput{}
+	if eip.isVPC() {
+		input.AllocationId = eip.AllocationId
+	} else {
+		input.PublicIp = eip.PublicIp
+	}
+	ec2C := newEC2(s)
+	if _, err := ec2C.ReleaseAddress(input); err != nil {
+		return fmt.Errorf("Couldn't release elastic IP [%s]: %s", *eip.PublicIp, err)
+	}
+	return nil
+}
+
+// Recovery returns the public IP, allocation and tags of the ElasticIP
+func (eip ElasticIP) Recovery(s *session.Session, artifact string) (*Recovery, error) {
+	return &Recovery{Address: &AddressRecovery{
+		PublicIP:     aws.StringValue(eip.PublicIp),
+		Domain:       aws.StringValue(eip.Domain),
+		AllocationID: aws.StringValue(eip.AllocationId),
+		Tags:         omitTags(ec2TagMap(eip.Tags), awsTagKeys),
+	}}, nil
+}
+
+// AddressRecovery holds the public IP of an ElasticIP, to allocate it
+// again while no other account took it, and its tags
+type AddressRecovery struct {
+	PublicIP     string            `json:"publicIp"`
+	Domain       string            `json:"domain"`
+	AllocationID string            `json:"allocationId,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+}
+
+// restore allocates the same public IP again, which only works while it
+// isn't allocated to another account
+func (r *AddressRecovery) restore(s *session.Session) (string, error) {
+	ec2C := newEC2(s)
+	res, err := ec2C.AllocateAddress(&ec2.AllocateAddressInput{
+		Address: aws.String(r.PublicIP),
+		Domain:  aws.String(r.Domain),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Couldn't allocate elastic IP [%s]: %s", r.PublicIP, err)
+	}
+	// EC2-Classic addresses have neither allocation ID nor tags.
+	if res.AllocationId == nil {
+		return *res.PublicIp, nil
+	}
+	if len(r.Tags) > 0 {
+		if _, err := ec2C.CreateTags(&ec2.CreateTagsInput{
+			Resources: []*string{res.AllocationId},
+			Tags:      ec2Tags(r.Tags),
+		}); err != nil {
+			return *res.AllocationId, fmt.Errorf("Couldn't tag elastic IP [%s]: %s", r.PublicIP, err)
+		}
+	}
+	return *res.AllocationId, nil
+}
+
+func init() {
+	RegisterKind(Kind{
+		Name:        "eip",
+		Aliases:     []string{"elastic-ip"},
+		Description: "unassociated Elastic IPs, their address and tags recorded in the journal",
+		List: func(s *session.Session) ([]Deletable, error) {
+			res, err := ListUnassociatedAddresses(s)
+			return deletables(res), err
+		},
+		Lookups: map[string]LookupFunc{ElasticIP{}.Type(): lookupAddress},
+	})
+}
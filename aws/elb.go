@@ -0,0 +1,192 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/elb"
+)
+
+// LoadBalancer is a proxy for the AWS framework struct
+type LoadBalancer struct {
+	*elb.LoadBalancerDescription
+}
+
+var _ = Deletable(&LoadBalancer{})
+var _ = Aged(&LoadBalancer{})
+var _ = Referencer(&LoadBalancer{})
+var _ = Recoverable(&LoadBalancer{})
+
+// lookupLoadBalancer returns the LoadBalancer of the given name
+func lookupLoadBalancer(s *session.Session, id string) (Deletable, error) {
+	elbC := elb.New(s)
+	res, err := elbC.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{
+		LoadBalancerNames: []*string{aws.String(id)},
+	})
+	if isNotFound(err) || (err == nil && len(res.LoadBalancerDescriptions) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, lookupError(LoadBalancer{}.Type(), id, err)
+	}
+	return LoadBalancer{res.LoadBalancerDescriptions[0]}, nil
+}
+
+// ListInactiveLoadBalancers returns a list of LoadBalancer that have no
+// EC2Instance attached to it.
+func ListInactiveLoadBalancers(s *session.Session) ([]LoadBalancer, error) {
+	res, err := describeLoadBalancers(s)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list load balancers: %s", err)
+	}
+	list := make([]LoadBalancer, 0, len(res))
+	for _, lb := range res {
+		if len(lb.Instances) == 0 {
+			list = append(list, LoadBalancer{lb})
+		}
+	}
+	return list, nil
+}
+
+// Type returns the ELB type
+func (lb LoadBalancer) Type() string { return "ELB" }
+
+// ID returns the LoadBalancer name
+func (lb LoadBalancer) ID() string { return *lb.LoadBalancerName }
+
+// Name returns the LoadBalancer name
+func (lb LoadBalancer) Name() string { return *lb.LoadBalancerName }
+
+// Reason explains the LoadBalancer is inactive
+func (lb LoadBalancer) Reason() string { return "no instance attached" }
+
+// Since returns when the LoadBalancer was created
+func (lb LoadBalancer) Since(s *session.Session) (time.Time, error) {
+	return aws.TimeValue(lb.CreatedTime), nil
+}
+
+// References returns the security groups of the LoadBalancer
+func (lb LoadBalancer) References() map[string][]string {
+	return map[string][]string{"security-group": aws.StringValueSlice(lb.SecurityGroups)}
+}
+
+// Delete the LoadBalancer
+func (lb LoadBalancer) Delete(s *session.Session) error {
+	elbC := elb.New(s)
+	if _, err := elbC.DeleteLoadBalancer(&elb.DeleteLoadBalancerInput{
+		LoadBalancerName: lb.LoadBalancerName,
+	}); err != nil {
+		return fmt.Errorf("Couldn't delete load balancer [%s]: %s", *lb.LoadBalancerName, err)
+	}
+	return nil
+}
+
+// Recovery returns the listeners, health check, attributes, placement and
+// tags of the LoadBalancer. Listener policies aren't recorded.
+func (lb LoadBalancer) Recovery(s *session.Session, artifact string) (*Recovery, error) {
+	elbC := elb.New(s)
+	attrs, err := elbC.DescribeLoadBalancerAttributes(&elb.DescribeLoadBalancerAttributesInput{
+		LoadBalancerName: lb.LoadBalancerName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't describe attributes of load balancer [%s]: %s",
+			*lb.LoadBalancerName, err)
+	}
+	tags, err := loadBalancerTags(s, []*string{lb.LoadBalancerName})
+	if err != nil {
+		return nil, err
+	}
+	r := &LoadBalancerRecovery{
+		HealthCheck:    lb.HealthCheck,
+		Attributes:     attrs.LoadBalancerAttributes,
+		Subnets:        aws.StringValueSlice(lb.Subnets),
+		SecurityGroups: aws.StringValueSlice(lb.SecurityGroups),
+		Scheme:         aws.StringValue(lb.Scheme),
+		Tags:           tags[*lb.LoadBalancerName],
+	}
+	// Availability zones are implied by the subnets in a VPC.
+	if len(r.Subnets) == 0 {
+		r.AvailabilityZones = aws.StringValueSlice(lb.AvailabilityZones)
+	}
+	for _, l := range lb.ListenerDescriptions {
+		r.Listeners = append(r.Listeners, l.Listener)
+	}
+	return &Recovery{LoadBalancer: r}, nil
+}
+
+// LoadBalancerRecovery holds the configuration of a LoadBalancer
+type LoadBalancerRecovery struct {
+	Listeners         []*elb.Listener             `json:"listeners"`
+	HealthCheck       *elb.HealthCheck            `json:"healthCheck,omitempty"`
+	Attributes        *elb.LoadBalancerAttributes `json:"attributes,omitempty"`
+	AvailabilityZones []string                    `json:"availabilityZones,omitempty"`
+	Subnets           []string                    `json:"subnets,omitempty"`
+	SecurityGroups    []string                    `json:"securityGroups,omitempty"`
+	Scheme            string                      `json:"scheme,omitempty"`
+	Tags              map[string]string           `json:"tags,omitempty"`
+}
+
+func (r *LoadBalancerRecovery) restore(s *session.Session, name string) (string, error) {
+	input := &elb.CreateLoadBalancerInput{
+		LoadBalancerName:  aws.String(name),
+		Listeners:         r.Listeners,
+		AvailabilityZones: aws.StringSlice(r.AvailabilityZones),
+		Subnets:           aws.StringSlice(r.Subnets),
+		SecurityGroups:    aws.StringSlice(r.SecurityGroups),
+	}
+	if r.Scheme != "" {
+		input.Scheme = aws.String(r.Scheme)
+	}
+	if len(r.Tags) > 0 {
+		input.Tags = elbTags(r.Tags)
+	}
+	elbC := elb.New(s)
+	if _, err := elbC.CreateLoadBalancer(input); err != nil {
+		return "", fmt.Errorf("Couldn't create load balancer [%s]: %s", name, err)
+	}
+	if r.HealthCheck != nil {
+		if _, err := elbC.ConfigureHealthCheck(&elb.ConfigureHealthCheckInput{
+			LoadBalancerName: aws.String(name),
+			HealthCheck:      r.HealthCheck,
+		}); err != nil {
+			return name, fmt.Errorf("Couldn't configure health check of load balancer [%s]: %s", name, err)
+		}
+	}
+	if r.Attributes != nil {
+		if _, err := elbC.ModifyLoadBalancerAttributes(&elb.ModifyLoadBalancerAttributesInput{
+			LoadBalancerName:       aws.String(name),
+			LoadBalancerAttributes: r.Attributes,
+		}); err != nil {
+			return name, fmt.Errorf("Couldn't set attributes of load balancer [%s]: %s", name, err)
+		}
+	}
+	return name, nil
+}
+
+// loadBalancersTags returns the tags of the listed LoadBalancer by name,
+// ELB doesn't return them along with the load balancers
+func loadBalancersTags(s *session.Session, list []Deletable) (map[string]map[string]string, error) {
+	names := make([]*string, 0, len(list))
+	for _, d := range list {
+		if lb, ok := d.(LoadBalancer); ok {
+			names = append(names, lb.LoadBalancerName)
+		}
+	}
+	return loadBalancerTags(s, names)
+}
+
+func init() {
+	RegisterKind(Kind{
+		Name:        "elb",
+		Aliases:     []string{"load-balancer"},
+		Description: "classic load balancers without instances",
+		List: func(s *session.Session) ([]Deletable, error) {
+			res, err := ListInactiveLoadBalancers(s)
+			return deletables(res), err
+		},
+		Lookups: map[string]LookupFunc{LoadBalancer{}.Type(): lookupLoadBalancer},
+		Tags:    loadBalancersTags,
+	})
+}
@@ -0,0 +1,504 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+)
+
+// newELBV2 returns the ELBv2 client of the session, tests replace it with
+// a fake
+var newELBV2 = func(s *session.Session) elbv2iface.ELBV2API { return elbv2.New(s) }
+
+// LoadBalancerV2 is a proxy for the AWS framework struct of the
+// application and network load balancers
+type LoadBalancerV2 struct {
+	*elbv2.LoadBalancer
+	// targetGroupArns are the target groups the listeners forward to
+	targetGroupArns []string
+	// inactive tells why the load balancer was listed
+	inactive string
+}
+
+var _ = Deletable(&LoadBalancerV2{})
+var _ = Sweetener(&LoadBalancerV2{})
+var _ = Aged(&LoadBalancerV2{})
+var _ = Referencer(&LoadBalancerV2{})
+
+// TargetGroup is a proxy for the AWS framework struct
+type TargetGroup struct {
+	*elbv2.TargetGroup
+}
+
+var _ = Deletable(&TargetGroup{})
+var _ = Sweetener(&TargetGroup{})
+var _ = Aged(&TargetGroup{})
+
+// ExportConfig configures the JSON export of the load balancers and
+// target groups done by their Sweeten
+type ExportConfig struct {
+	// Dir is the directory the exports are written to
+	Dir string
+}
+
+// Exporting is the ExportConfig of LoadBalancerV2.Sweeten and
+// TargetGroup.Sweeten
+var Exporting = ExportConfig{Dir: "."}
+
+// ListInactiveLoadBalancersV2 returns the application and network load
+// balancers whose target groups have no registered target or no healthy
+// one. Load balancers without target group are only returned when they
+// have no listener either, their listeners may redirect or answer by
+// themselves.
+func ListInactiveLoadBalancersV2(s *session.Session) ([]LoadBalancerV2, error) {
+	res, err := describeLoadBalancersV2(s, &elbv2.DescribeLoadBalancersInput{})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list load balancers: %s", err)
+	}
+	groups, err := describeTargetGroups(s, &elbv2.DescribeTargetGroupsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list target groups: %s", err)
+	}
+	registered := make(map[string]int, len(groups))
+	healthy := make(map[string]int, len(groups))
+	for _, tg := range groups {
+		if len(tg.LoadBalancerArns) == 0 {
+			continue
+		}
+		r, h, err := countTargets(s, tg.TargetGroupArn)
+		if err != nil {
+			return nil, err
+		}
+		registered[*tg.TargetGroupArn], healthy[*tg.TargetGroupArn] = r, h
+	}
+	list := make([]LoadBalancerV2, 0, len(res))
+	for _, lb := range res {
+		var arns []string
+		var r, h int
+		for _, tg := range groups {
+			for _, arn := range tg.LoadBalancerArns {
+				if *arn == *lb.LoadBalancerArn {
+					arns = append(arns, *tg.TargetGroupArn)
+					r += registered[*tg.TargetGroupArn]
+					h += healthy[*tg.TargetGroupArn]
+				}
+			}
+		}
+		var inactive string
+		switch {
+		case len(arns) == 0:
+			listeners, err := describeListeners(s, &elbv2.DescribeListenersInput{LoadBalancerArn: lb.LoadBalancerArn})
+			if err != nil {
+				return nil, fmt.Errorf("Couldn't describe listeners of load balancer [%s]: %s", *lb.LoadBalancerName, err)
+			}
+			if len(listeners) == 0 {
+				inactive = "no listener nor target group"
+			}
+		case r == 0:
+			inactive = "no registered target"
+		case h == 0:
+			inactive = "no healthy target"
+		}
+		if inactive != "" {
+			list = append(list, LoadBalancerV2{lb, arns, inactive})
+		}
+	}
+	return list, nil
+}
+
+// countTargets returns the number of targets registered to the target
+// group and how many of them are healthy
+func countTargets(s *session.Session, arn *string) (registered, healthy int, err error) {
+	res, err := newELBV2(s).DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: arn,
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("Couldn't describe targets of target group [%s]: %s", *arn, err)
+	}
+	for _, t := range res.TargetHealthDescriptions {
+		if t.TargetHealth != nil && aws.StringValue(t.TargetHealth.State) == elbv2.TargetHealthStateEnumHealthy {
+			healthy++
+		}
+	}
+	return len(res.TargetHealthDescriptions), healthy, nil
+}
+
+// lookupLoadBalancerV2 returns the LoadBalancerV2 of the given ARN
+func lookupLoadBalancerV2(s *session.Session, id string) (Deletable, error) {
+	res, err := newELBV2(s).DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{
+		LoadBalancerArns: []*string{aws.String(id)},
+	})
+	if isNotFound(err) || (err == nil && len(res.LoadBalancers) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, lookupError(LoadBalancerV2{}.Type(), id, err)
+	}
+	return LoadBalancerV2{LoadBalancer: res.LoadBalancers[0]}, nil
+}
+
+// Type returns the ELBv2 type
+func (lb LoadBalancerV2) Type() string { return "ELBv2" }
+
+// ID returns the LoadBalancerV2 ARN
+func (lb LoadBalancerV2) ID() string { return *lb.LoadBalancerArn }
+
+// Name returns the LoadBalancerV2 name
+func (lb LoadBalancerV2) Name() string { return *lb.LoadBalancerName }
+
+// Reason explains the LoadBalancerV2 is inactive
+func (lb LoadBalancerV2) Reason() string {
+	if lb.inactive == "" {
+		return "inactive"
+	}
+	return lb.inactive
+}
+
+// Since returns when the LoadBalancerV2 was created
+func (lb LoadBalancerV2) Since(s *session.Session) (time.Time, error) {
+	return aws.TimeValue(lb.CreatedTime), nil
+}
+
+// References returns the security groups and target groups of the
+// LoadBalancerV2
+func (lb LoadBalancerV2) References() map[string][]string {
+	return map[string][]string{
+		"security-group": aws.StringValueSlice(lb.SecurityGroups),
+		"target-group":   lb.targetGroupArns,
+	}
+}
+
+// SweetenSteps describes the export of the LoadBalancerV2
+func (lb LoadBalancerV2) SweetenSteps() []string {
+	return []string{"export listeners, rules and target groups of " + *lb.LoadBalancerName + " to JSON"}
+}
+
+// Sweeten exports the attributes, listeners, rules and target groups of
+// the LoadBalancerV2 to a JSON file in Exporting.Dir and returns its path
+func (lb LoadBalancerV2) Sweeten(ctx aws.Context, s *session.Session, out io.Writer) (string, error) {
+	elbv2C := newELBV2(s)
+	attrs, err := elbv2C.DescribeLoadBalancerAttributesWithContext(ctx, &elbv2.DescribeLoadBalancerAttributesInput{
+		LoadBalancerArn: lb.LoadBalancerArn,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Couldn't describe attributes of load balancer [%s]: %s", *lb.LoadBalancerName, err)
+	}
+	tags, err := elbv2Tags(s, []*string{lb.LoadBalancerArn})
+	if err != nil {
+		return "", err
+	}
+	export := loadBalancerExport{
+		LoadBalancer: lb.LoadBalancer,
+		Attributes:   attrs.Attributes,
+		Tags:         tags[*lb.LoadBalancerArn],
+	}
+	listeners, err := describeListeners(s, &elbv2.DescribeListenersInput{LoadBalancerArn: lb.LoadBalancerArn})
+	if err != nil {
+		return "", fmt.Errorf("Couldn't describe listeners of load balancer [%s]: %s", *lb.LoadBalancerName, err)
+	}
+	for _, l := range listeners {
+		rules, err := describeRules(s, &elbv2.DescribeRulesInput{ListenerArn: l.ListenerArn})
+		if err != nil {
+			return "", fmt.Errorf("Couldn't describe rules of listener [%s]: %s", *l.ListenerArn, err)
+		}
+		export.Listeners = append(export.Listeners, listenerExport{l, rules})
+	}
+	groups, err := describeTargetGroups(s, &elbv2.DescribeTargetGroupsInput{LoadBalancerArn: lb.LoadBalancerArn})
+	if err != nil {
+		return "", fmt.Errorf("Couldn't describe target groups of load balancer [%s]: %s", *lb.LoadBalancerName, err)
+	}
+	for _, tg := range groups {
+		e, err := TargetGroup{tg}.export(ctx, s)
+		if err != nil {
+			return "", err
+		}
+		export.TargetGroups = append(export.TargetGroups, *e)
+	}
+	return writeExport(out, "elbv2", lb.Type(), *lb.LoadBalancerName, *lb.LoadBalancerArn, export)
+}
+
+// Delete the LoadBalancerV2 along with its listeners and rules
+func (lb LoadBalancerV2) Delete(s *session.Session) error {
+	elbv2C := newELBV2(s)
+	if _, err := elbv2C.DeleteLoadBalancer(&elbv2.DeleteLoadBalancerInput{
+		LoadBalancerArn: lb.LoadBalancerArn,
+	}); err != nil {
+		return fmt.Errorf("Couldn't delete load balancer [%s]: %s", *lb.LoadBalancerName, err)
+	}
+	return nil
+}
+
+// ListUnattachedTargetGroups returns the target groups attached to no load
+// balancer
+func ListUnattachedTargetGroups(s *session.Session) ([]TargetGroup, error) {
+	res, err := describeTargetGroups(s, &elbv2.DescribeTargetGroupsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list target groups: %s", err)
+	}
+	list := make([]TargetGroup, 0, len(res))
+	for _, tg := range res {
+		if len(tg.LoadBalancerArns) == 0 {
+			list = append(list, TargetGroup{tg})
+		}
+	}
+	return list, nil
+}
+
+// lookupTargetGroup returns the TargetGroup of the given ARN
+func lookupTargetGroup(s *session.Session, id string) (Deletable, error) {
+	res, err := newELBV2(s).DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+		TargetGroupArns: []*string{aws.String(id)},
+	})
+	if isNotFound(err) || (err == nil && len(res.TargetGroups) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, lookupError(TargetGroup{}.Type(), id, err)
+	}
+	return TargetGroup{res.TargetGroups[0]}, nil
+}
+
+// Type returns the Target Group type
+func (tg TargetGroup) Type() string { return "Target Group" }
+
+// ID returns the TargetGroup ARN
+func (tg TargetGroup) ID() string { return *tg.TargetGroupArn }
+
+// Name returns the TargetGroup name
+func (tg TargetGroup) Name() string { return *tg.TargetGroupName }
+
+// Reason explains the TargetGroup is unattached
+func (tg TargetGroup) Reason() string { return "not attached to any load balancer" }
+
+// Since returns when the TargetGroup was created according to CloudTrail
+func (tg TargetGroup) Since(s *session.Session) (time.Time, error) {
+	return lastEvent(s, *tg.TargetGroupArn, time.Time{}, "CreateTargetGroup")
+}
+
+// SweetenSteps describes the export of the TargetGroup
+func (tg TargetGroup) SweetenSteps() []string {
+	return []string{"export target group " + *tg.TargetGroupName + " to JSON"}
+}
+
+// Sweeten exports the configuration and targets of the TargetGroup to a
+// JSON file in Exporting.Dir and returns its path
+func (tg TargetGroup) Sweeten(ctx aws.Context, s *session.Session, out io.Writer) (string, error) {
+	export, err := tg.export(ctx, s)
+	if err != nil {
+		return "", err
+	}
+	return writeExport(out, "target-group", tg.Type(), *tg.TargetGroupName, *tg.TargetGroupArn, export)
+}
+
+// export returns the configuration and targets of the TargetGroup
+func (tg TargetGroup) export(ctx aws.Context, s *session.Session) (*targetGroupExport, error) {
+	elbv2C := newELBV2(s)
+	attrs, err := elbv2C.DescribeTargetGroupAttributesWithContext(ctx, &elbv2.DescribeTargetGroupAttributesInput{
+		TargetGroupArn: tg.TargetGroupArn,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't describe attributes of target group [%s]: %s", *tg.TargetGroupName, err)
+	}
+	health, err := elbv2C.DescribeTargetHealthWithContext(ctx, &elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: tg.TargetGroupArn,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't describe targets of target group [%s]: %s", *tg.TargetGroupName, err)
+	}
+	tags, err := elbv2Tags(s, []*string{tg.TargetGroupArn})
+	if err != nil {
+		return nil, err
+	}
+	return &targetGroupExport{
+		TargetGroup: tg.TargetGroup,
+		Attributes:  attrs.Attributes,
+		Targets:     health.TargetHealthDescriptions,
+		Tags:        tags[*tg.TargetGroupArn],
+	}, nil
+}
+
+// Delete the TargetGroup
+func (tg TargetGroup) Delete(s *session.Session) error {
+	elbv2C := newELBV2(s)
+	if _, err := elbv2C.DeleteTargetGroup(&elbv2.DeleteTargetGroupInput{
+		TargetGroupArn: tg.TargetGroupArn,
+	}); err != nil {
+		return fmt.Errorf("Couldn't delete target group [%s]: %s", *tg.TargetGroupName, err)
+	}
+	return nil
+}
+
+// loadBalancerExport is what LoadBalancerV2.Sweeten writes
+type loadBalancerExport struct {
+	LoadBalancer *elbv2.LoadBalancer            `json:"loadBalancer"`
+	Attributes   []*elbv2.LoadBalancerAttribute `json:"attributes"`
+	Listeners    []listenerExport               `json:"listeners"`
+	TargetGroups []targetGroupExport            `json:"targetGroups"`
+	Tags         map[string]string              `json:"tags,omitempty"`
+}
+
+type listenerExport struct {
+	Listener *elbv2.Listener `json:"listener"`
+	Rules    []*elbv2.Rule   `json:"rules"`
+}
+
+// targetGroupExport is what TargetGroup.Sweeten writes
+type targetGroupExport struct {
+	TargetGroup *elbv2.TargetGroup               `json:"targetGroup"`
+	Attributes  []*elbv2.TargetGroupAttribute    `json:"attributes"`
+	Targets     []*elbv2.TargetHealthDescription `json:"targets"`
+	Tags        map[string]string                `json:"tags,omitempty"`
+}
+
+// writeExport writes the export of the resource of the given ARN to a JSON
+// file in Exporting.Dir named after the kind, account, region and name of
+// the resource, telling where to out
+func writeExport(out io.Writer, kind, typ, name, arn string, export interface{}) (string, error) {
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Couldn't encode export of %s [%s]: %s", typ, name, err)
+	}
+	account, region := arnAccountRegion(arn)
+	path := filepath.Join(Exporting.Dir, fmt.Sprintf("awsugar-%s-%s-%s-%s-%s.json",
+		kind, account, region, name, time.Now().UTC().Format("20060102-150405")))
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("Couldn't write export of %s [%s]: %s", typ, name, err)
+	}
+	fmt.Fprintf(out, "%s [%s] exported to %s\n", typ, name, path)
+	return path, nil
+}
+
+// arnAccountRegion returns the account and region of the ARN,
+// arn:partition:service:region:account:resource
+func arnAccountRegion(arn string) (account, region string) {
+	fields := strings.SplitN(arn, ":", 6)
+	if len(fields) < 6 {
+		return "", ""
+	}
+	return fields[4], fields[3]
+}
+
+// elbv2ListedTags returns the tags of the listed load balancers and target
+// groups by ARN, ELBv2 doesn't return them along with the resources
+func elbv2ListedTags(s *session.Session, list []Deletable) (map[string]map[string]string, error) {
+	arns := make([]*string, 0, len(list))
+	for _, d := range list {
+		arns = append(arns, aws.String(d.ID()))
+	}
+	return elbv2Tags(s, arns)
+}
+
+// elbv2TagsBatchSize is the maximum number of resources DescribeTags accepts
+const elbv2TagsBatchSize = 20
+
+// elbv2Tags returns the tags of the load balancers or target groups by ARN
+func elbv2Tags(s *session.Session, arns []*string) (map[string]map[string]string, error) {
+	elbv2C := newELBV2(s)
+	tagsByArn := make(map[string]map[string]string, len(arns))
+	for start := 0; start < len(arns); start += elbv2TagsBatchSize {
+		end := start + elbv2TagsBatchSize
+		if end > len(arns) {
+			end = len(arns)
+		}
+		res, err := elbv2C.DescribeTags(&elbv2.DescribeTagsInput{ResourceArns: arns[start:end]})
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't describe load balancers tags: %s", err)
+		}
+		for _, desc := range res.TagDescriptions {
+			tagsByArn[*desc.ResourceArn] = elbv2TagMap(desc.Tags)
+		}
+	}
+	return tagsByArn, nil
+}
+
+func elbv2TagMap(tags []*elbv2.Tag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, t := range tags {
+		m[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return m
+}
+
+// The describe helpers below return every page of the Describe calls of
+// ELBv2.
+
+func describeLoadBalancersV2(s *session.Session, input *elbv2.DescribeLoadBalancersInput) ([]*elbv2.LoadBalancer, error) {
+	var list []*elbv2.LoadBalancer
+	err := newELBV2(s).DescribeLoadBalancersPages(input,
+		func(page *elbv2.DescribeLoadBalancersOutput, lastPage bool) bool {
+			list = append(list, page.LoadBalancers...)
+			return true
+		})
+	return list, err
+}
+
+func describeTargetGroups(s *session.Session, input *elbv2.DescribeTargetGroupsInput) ([]*elbv2.TargetGroup, error) {
+	var list []*elbv2.TargetGroup
+	err := newELBV2(s).DescribeTargetGroupsPages(input,
+		func(page *elbv2.DescribeTargetGroupsOutput, lastPage bool) bool {
+			list = append(list, page.TargetGroups...)
+			return true
+		})
+	return list, err
+}
+
+func describeListeners(s *session.Session, input *elbv2.DescribeListenersInput) ([]*elbv2.Listener, error) {
+	var list []*elbv2.Listener
+	err := newELBV2(s).DescribeListenersPages(input,
+		func(page *elbv2.DescribeListenersOutput, lastPage bool) bool {
+			list = append(list, page.Listeners...)
+			return true
+		})
+	return list, err
+}
+
+func describeRules(s *session.Session, input *elbv2.DescribeRulesInput) ([]*elbv2.Rule, error) {
+	var list []*elbv2.Rule
+	elbv2C := newELBV2(s)
+	err := eachPage(func(marker *string) (*string, error) {
+		input.Marker = marker
+		res, err := elbv2C.DescribeRules(input)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, res.Rules...)
+		return res.NextMarker, nil
+	})
+	return list, err
+}
+
+func init() {
+	RegisterKind(Kind{
+		Name:        "elbv2",
+		Aliases:     []string{"alb", "nlb"},
+		Description: "application and network load balancers without registered or healthy target, exported to JSON first",
+		List: func(s *session.Session) ([]Deletable, error) {
+			res, err := ListInactiveLoadBalancersV2(s)
+			return deletables(res), err
+		},
+		Lookups: map[string]LookupFunc{LoadBalancerV2{}.Type(): lookupLoadBalancerV2},
+		Tags:    elbv2ListedTags,
+		Sweeten: true,
+	})
+	RegisterKind(Kind{
+		Name:        "target-group",
+		Aliases:     []string{"tg"},
+		Description: "target groups not attached to any load balancer, exported to JSON first",
+		List: func(s *session.Session) ([]Deletable, error) {
+			res, err := ListUnattachedTargetGroups(s)
+			return deletables(res), err
+		},
+		Lookups: map[string]LookupFunc{TargetGroup{}.Type(): lookupTargetGroup},
+		Tags:    elbv2ListedTags,
+		Sweeten: true,
+	})
+}
@@ -0,0 +1,131 @@
+package aws
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+)
+
+// fakeELBV2 answers the listing calls of the tests, the others panic
+type fakeELBV2 struct {
+	elbv2iface.ELBV2API
+	loadBalancers []*elbv2.LoadBalancer
+	targetGroups  []*elbv2.TargetGroup
+	// listeners are the number of listeners of each load balancer
+	listeners map[string]int
+	// targets are the health states of the targets of each target group
+	targets map[string][]string
+}
+
+func (f *fakeELBV2) DescribeLoadBalancersPages(input *elbv2.DescribeLoadBalancersInput, fn func(*elbv2.DescribeLoadBalancersOutput, bool) bool) error {
+	fn(&elbv2.DescribeLoadBalancersOutput{LoadBalancers: f.loadBalancers}, true)
+	return nil
+}
+
+func (f *fakeELBV2) DescribeTargetGroupsPages(input *elbv2.DescribeTargetGroupsInput, fn func(*elbv2.DescribeTargetGroupsOutput, bool) bool) error {
+	fn(&elbv2.DescribeTargetGroupsOutput{TargetGroups: f.targetGroups}, true)
+	return nil
+}
+
+func (f *fakeELBV2) DescribeListenersPages(input *elbv2.DescribeListenersInput, fn func(*elbv2.DescribeListenersOutput, bool) bool) error {
+	var listeners []*elbv2.Listener
+	for i := 0; i < f.listeners[*input.LoadBalancerArn]; i++ {
+		listeners = append(listeners, &elbv2.Listener{LoadBalancerArn: input.LoadBalancerArn})
+	}
+	fn(&elbv2.DescribeListenersOutput{Listeners: listeners}, true)
+	return nil
+}
+
+func (f *fakeELBV2) DescribeTargetHealth(input *elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error) {
+	var res elbv2.DescribeTargetHealthOutput
+	for _, state := range f.targets[*input.TargetGroupArn] {
+		res.TargetHealthDescriptions = append(res.TargetHealthDescriptions, &elbv2.TargetHealthDescription{
+			TargetHealth: &elbv2.TargetHealth{State: aws.String(state)},
+		})
+	}
+	return &res, nil
+}
+
+// useFakeELBV2 makes newELBV2 return f, until the returned func restores it
+func useFakeELBV2(f elbv2iface.ELBV2API) func() {
+	prev := newELBV2
+	newELBV2 = func(s *session.Session) elbv2iface.ELBV2API { return f }
+	return func() { newELBV2 = prev }
+}
+
+func TestListInactiveLoadBalancersV2(t *testing.T) {
+	lb := func(name string) *elbv2.LoadBalancer {
+		return &elbv2.LoadBalancer{LoadBalancerName: aws.String(name), LoadBalancerArn: aws.String("arn:" + name)}
+	}
+	tg := func(name string, lbs ...string) *elbv2.TargetGroup {
+		g := &elbv2.TargetGroup{TargetGroupArn: aws.String("arn:" + name)}
+		for _, lb := range lbs {
+			g.LoadBalancerArns = append(g.LoadBalancerArns, aws.String("arn:"+lb))
+		}
+		return g
+	}
+	defer useFakeELBV2(&fakeELBV2{
+		loadBalancers: []*elbv2.LoadBalancer{
+			lb("empty"), lb("unhealthy"), lb("healthy"), lb("listener"), lb("bare"), lb("mixed"),
+		},
+		targetGroups: []*elbv2.TargetGroup{
+			tg("empty-tg", "empty"),
+			tg("unhealthy-tg", "unhealthy"),
+			tg("healthy-tg", "healthy"),
+			tg("mixed-empty-tg", "mixed"),
+			tg("mixed-healthy-tg", "mixed"),
+			tg("unattached-tg"),
+		},
+		listeners: map[string]int{"arn:listener": 1},
+		targets: map[string][]string{
+			"arn:unhealthy-tg":     {elbv2.TargetHealthStateEnumUnhealthy, elbv2.TargetHealthStateEnumDraining},
+			"arn:healthy-tg":       {elbv2.TargetHealthStateEnumUnhealthy, elbv2.TargetHealthStateEnumHealthy},
+			"arn:mixed-healthy-tg": {elbv2.TargetHealthStateEnumHealthy},
+		},
+	})()
+
+	list, err := ListInactiveLoadBalancersV2(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, lb := range list {
+		got = append(got, *lb.LoadBalancerName+": "+lb.Reason())
+	}
+	sort.Strings(got)
+	want := []string{
+		"bare: no listener nor target group",
+		"empty: no registered target",
+		"unhealthy: no healthy target",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listed %q, want %q", got, want)
+	}
+}
+
+func TestARNAccountRegion(t *testing.T) {
+	for _, tc := range []struct {
+		arn, account, region string
+	}{
+		{
+			"arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/app/web/50dc6c495c0c9188",
+			"123456789012", "eu-west-1",
+		},
+		{
+			"arn:aws:elasticloadbalancing:us-east-1:210987654321:targetgroup/web:80/73e2d6bc24d8a067",
+			"210987654321", "us-east-1",
+		},
+		{"web", "", ""},
+		{"", "", ""},
+	} {
+		account, region := arnAccountRegion(tc.arn)
+		if account != tc.account || region != tc.region {
+			t.Errorf("arnAccountRegion(%q) = %q, %q, want %q, %q", tc.arn, account, region, tc.account, tc.region)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+// serviceAliases maps the names of the SDK clients to the endpoint IDs
+// of their service
+var serviceAliases = map[string]string{
+	"elb":                      "elasticloadbalancing",
+	"elbv2":                    "elasticloadbalancing",
+	"resourcegroupstaggingapi": "tagging",
+}
+
+// EndpointResolver returns a resolver using the URL of the overrides for
+// the services they're given for, keyed by endpoint ID or client name.
+// The URL given for "*" is used by every other service. Services without
+// override are resolved through the default resolver.
+func EndpointResolver(overrides map[string]string) endpoints.Resolver {
+	urls := make(map[string]string, len(overrides))
+	for service, url := range overrides {
+		if id, ok := serviceAliases[service]; ok {
+			service = id
+		}
+		urls[service] = url
+	}
+	return endpoints.ResolverFunc(func(service, region string,
+		opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		url, ok := urls[service]
+		if !ok {
+			url, ok = urls["*"]
+		}
+		if !ok {
+			return endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+		}
+		return endpoints.ResolvedEndpoint{
+			URL:           url,
+			SigningRegion: region,
+		}, nil
+	})
+}
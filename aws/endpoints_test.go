@@ -0,0 +1,16 @@
+package aws
+
+import "testing"
+
+func TestEndpointResolverAliases(t *testing.T) {
+	for _, service := range []string{"elb", "elbv2", "elasticloadbalancing"} {
+		r := EndpointResolver(map[string]string{service: "http://localhost:4566"})
+		e, err := r.EndpointFor("elasticloadbalancing", "us-east-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.URL != "http://localhost:4566" {
+			t.Errorf("%s: URL = %s, want the override", service, e.URL)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// NetworkInterface is a proxy for the AWS framework struct
+type NetworkInterface struct {
+	*ec2.NetworkInterface
+}
+
+var _ = Deletable(&NetworkInterface{})
+var _ = Aged(&NetworkInterface{})
+var _ = Tagged(&NetworkInterface{})
+var _ = Referencer(&NetworkInterface{})
+var _ = Recoverable(&NetworkInterface{})
+
+// ListUnattachedNetworkInterfaces returns a list of NetworkInterface
+// that are currently not attached to an EC2Instance
+func ListUnattachedNetworkInterfaces(s *session.Session) ([]NetworkInterface, error) {
+	res, err := describeNetworkInterfaces(s, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("status"),
+				Values: []*string{aws.String("available")},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list network interfaces: %s", err)
+	}
+	list := make([]NetworkInterface, 0, len(res))
+	for _, ni := range res {
+		list = append(list, NetworkInterface{ni})
+	}
+	return list, nil
+}
+
+// lookupNetworkInterface returns the NetworkInterface of the given ID
+func lookupNetworkInterface(s *session.Session, id string) (Deletable, error) {
+	res, err := describeNetworkInterfaces(s, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{idFilter("network-interface-id", id)},
+	})
+	if err != nil || len(res) == 0 {
+		return nil, lookupError(NetworkInterface{}.Type(), id, err)
+	}
+	return NetworkInterface{res[0]}, nil
+}
+
+// Type returns the Network Interface type
+func (ni NetworkInterface) Type() string { return "Network Interface" }
+
+// ID returns the NetworkInterface ID
+func (ni NetworkInterface) ID() string { return *ni.NetworkInterfaceId }
+
+// Name returns the NetworkInterface ID
+func (ni NetworkInterface) Name() string { return *ni.NetworkInterfaceId }
+
+// Reason explains the NetworkInterface is unattached
+func (ni NetworkInterface) Reason() string { return "not attached" }
+
+// Since returns when the NetworkInterface was detached according to
+// CloudTrail, or created if it was never attached
+func (ni NetworkInterface) Since(s *session.Session) (time.Time, error) {
+	return lastEvent(s, *ni.NetworkInterfaceId, time.Time{}, "DetachNetworkInterface", "CreateNetworkInterface")
+}
+
+// TagMap returns the tags of the NetworkInterface
+func (ni NetworkInterface) TagMap() map[string]string { return ec2TagMap(ni.TagSet) }
+
+// References returns the security groups and Elastic IP of the
+// NetworkInterface
+func (ni NetworkInterface) References() map[string][]string {
+	refs := make(map[string][]string)
+	for _, g := range ni.Groups {
+		refs["security-group"] = append(refs["security-group"], aws.StringValue(g.GroupId))
+	}
+	if ni.Association != nil && ni.Association.AllocationId != nil {
+		refs["eip"] = []string{*ni.Association.AllocationId}
+	}
+	return refs
+}
+
+// Delete the NetworkInterface
+func (ni NetworkInterface) Delete(s *session.Session) error {
+	ec2C := newEC2(s)
+	if _, err := ec2C.DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceInput{
+		NetworkInterfaceId: ni.NetworkInterfaceId,
+	}); err != nil {
+		return fmt.Errorf("Couldn't delete network interface [%s]: %s", *ni.NetworkInterfaceId, err)
+	}
+	return nil
+}
+
+// Recovery returns the subnet, groups, private IPs and tags of the
+// NetworkInterface
+func (ni NetworkInterface) Recovery(s *session.Session, artifact string) (*Recovery, error) {
+	r := &NetworkInterfaceRecovery{
+		SubnetID:    aws.StringValue(ni.SubnetId),
+		Description: aws.StringValue(ni.Description),
+		Tags:        omitTags(ec2TagMap(ni.TagSet), awsTagKeys),
+	}
+	for _, g := range ni.Groups {
+		r.Groups = append(r.Groups, aws.StringValue(g.GroupId))
+	}
+	r.PrivateIPs = append(r.PrivateIPs, aws.StringValue(ni.PrivateIpAddress))
+	for _, ip := range ni.PrivateIpAddresses {
+		if !aws.BoolValue(ip.Primary) {
+			r.PrivateIPs = append(r.PrivateIPs, aws.StringValue(ip.PrivateIpAddress))
+		}
+	}
+	return &Recovery{NetworkInterface: r}, nil
+}
+
+// NetworkInterfaceRecovery holds the placement and addresses of a
+// NetworkInterface
+type NetworkInterfaceRecovery struct {
+	SubnetID    string   `json:"subnetId"`
+	Description string   `json:"description,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+	// PrivateIPs starts with the primary private IP
+	PrivateIPs []string          `json:"privateIps"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+func (r *NetworkInterfaceRecovery) restore(s *session.Session) (string, error) {
+	input := &ec2.CreateNetworkInterfaceInput{
+		SubnetId: aws.String(r.SubnetID),
+		Groups:   aws.StringSlice(r.Groups),
+	}
+	if r.Description != "" {
+		input.Description = aws.String(r.Description)
+	}
+	for i, ip := range r.PrivateIPs {
+		input.PrivateIpAddresses = append(input.PrivateIpAddresses, &ec2.PrivateIpAddressSpecification{
+			PrivateIpAddress: aws.String(ip),
+			Primary:          aws.Bool(i == 0),
+		})
+	}
+	ec2C := newEC2(s)
+	res, err := ec2C.CreateNetworkInterface(input)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't create network interface in subnet [%s]: %s", r.SubnetID, err)
+	}
+	id := res.NetworkInterface.NetworkInterfaceId
+	if len(r.Tags) > 0 {
+		if _, err := ec2C.CreateTags(&ec2.CreateTagsInput{
+			Resources: []*string{id},
+			Tags:      ec2Tags(r.Tags),
+		}); err != nil {
+			return *id, fmt.Errorf("Couldn't tag network interface [%s]: %s", *id, err)
+		}
+	}
+	return *id, nil
+}
+
+func init() {
+	RegisterKind(Kind{
+		Name:        "network-interface",
+		Aliases:     []string{"eni"},
+		Description: "unattached network interfaces",
+		List: func(s *session.Session) ([]Deletable, error) {
+			res, err := ListUnattachedNetworkInterfaces(s)
+			return deletables(res), err
+		},
+		Lookups: map[string]LookupFunc{NetworkInterface{}.Type(): lookupNetworkInterface},
+	})
+}
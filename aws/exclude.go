@@ -0,0 +1,93 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Tagged provides an interface for Deletables carrying their tags
+type Tagged interface {
+	TagMap() map[string]string
+}
+
+// Exclusion keeps resources out of the lists to clean
+type Exclusion struct {
+	// Protection is the tag of the resources to never clean, disabled
+	// when its Key is empty
+	Protection TagFilter
+	Tags       []TagFilter
+	IDs        []string
+}
+
+// Excluded is a resource kept out of a list to clean
+type Excluded struct {
+	Deletable
+	// Why explains why the resource was excluded
+	Why string
+}
+
+// Filter splits the list between the resources to clean and the
+// excluded ones. Resources without tags, such as launch configurations,
+// can only be excluded by ID.
+func (x Exclusion) Filter(s *session.Session, list []Deletable) ([]Deletable, []Excluded, error) {
+	tags, err := listedTags(s, list)
+	if err != nil {
+		return nil, nil, err
+	}
+	kept := make([]Deletable, 0, len(list))
+	var excluded []Excluded
+	for _, d := range list {
+		if why := x.why(d.ID(), tags[d.Type()][d.ID()]); why != "" {
+			excluded = append(excluded, Excluded{d, why})
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept, excluded, nil
+}
+
+// listedTags returns the tags of the resources by Type and ID, from the
+// resources when Tagged, otherwise from the Tags of their kind
+func listedTags(s *session.Session, list []Deletable) (map[string]map[string]map[string]string, error) {
+	tags := make(map[string]map[string]map[string]string)
+	untagged := make(map[string][]Deletable)
+	for _, d := range list {
+		if tags[d.Type()] == nil {
+			tags[d.Type()] = make(map[string]map[string]string)
+		}
+		if t, ok := d.(Tagged); ok {
+			tags[d.Type()][d.ID()] = t.TagMap()
+		} else {
+			untagged[d.Type()] = append(untagged[d.Type()], d)
+		}
+	}
+	for typ, l := range untagged {
+		k, ok := KindOfType(typ)
+		if !ok || k.Tags == nil {
+			continue
+		}
+		res, err := k.Tags(s, l)
+		if err != nil {
+			return nil, err
+		}
+		tags[typ] = res
+	}
+	return tags, nil
+}
+
+// why returns why the resource is excluded, or an empty string if it isn't
+func (x Exclusion) why(id string, tags map[string]string) string {
+	if x.Protection.Key != "" && x.Protection.Match(tags) {
+		return "protected by tag " + x.Protection.String()
+	}
+	for _, f := range x.Tags {
+		if f.Match(tags) {
+			return "excluded by tag " + f.String()
+		}
+	}
+	for _, excludedID := range x.IDs {
+		if excludedID == id {
+			return "excluded by ID"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,420 @@
+package aws
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// EC2Instance is a proxy for the AWS framework struct
+type EC2Instance struct {
+	*ec2.Instance
+	// sweetening overrides InstanceSweetening, see WithSweetening
+	sweetening *InstanceSweeteningConfig
+}
+
+var _ = Deletable(&EC2Instance{})
+var _ = Sweetener(&EC2Instance{})
+var _ = Aged(&EC2Instance{})
+var _ = Tagged(&EC2Instance{})
+var _ = Referencer(&EC2Instance{})
+var _ = Recoverable(&EC2Instance{})
+
+// InstanceSelector narrows down the instances returned by ListInstances.
+// The zero value selects every instance of the region.
+type InstanceSelector struct {
+	IDs        []string
+	Tags       []TagFilter
+	State      string
+	StoppedFor time.Duration
+}
+
+// ListInstances returns the list of EC2Instance matching the selector
+func ListInstances(s *session.Session, sel InstanceSelector) ([]EC2Instance, error) {
+	input := &ec2.DescribeInstancesInput{
+		Filters: ec2TagFilters(sel.Tags),
+	}
+	if len(sel.IDs) > 0 {
+		input.InstanceIds = aws.StringSlice(sel.IDs)
+	}
+	state := sel.State
+	if sel.StoppedFor > 0 {
+		state = ec2.InstanceStateNameStopped
+	}
+	if state != "" {
+		input.Filters = append(input.Filters, &ec2.Filter{
+			Name:   aws.String("instance-state-name"),
+			Values: []*string{aws.String(state)},
+		})
+	}
+	if len(input.Filters) == 0 {
+		input.Filters = nil
+	}
+	res, err := describeInstances(s, input)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list instances: %s", err)
+	}
+	var list []EC2Instance
+	for _, is := range res {
+		e := EC2Instance{Instance: is}
+		if sel.StoppedFor > 0 {
+			since, ok := e.StoppedSince()
+			if !ok || time.Since(since) < sel.StoppedFor {
+				continue
+			}
+		}
+		list = append(list, e)
+	}
+	return list, nil
+}
+
+// lookupInstance returns the EC2Instance of the given ID
+func lookupInstance(s *session.Session, id string) (Deletable, error) {
+	res, err := describeInstances(s, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{idFilter("instance-id", id)},
+	})
+	if err != nil || len(res) == 0 {
+		return nil, lookupError(EC2Instance{}.Type(), id, err)
+	}
+	return EC2Instance{Instance: res[0]}, nil
+}
+
+// stoppedReason matches the state transition reason of an instance
+// stopped by a user, e.g. "User initiated (2018-05-30 12:34:56 GMT)"
+var stoppedReason = regexp.MustCompile(`\((\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) GMT\)`)
+
+// StoppedSince returns when the EC2Instance was stopped, as reported by
+// its state transition reason
+func (e EC2Instance) StoppedSince() (time.Time, bool) {
+	if e.State == nil || aws.StringValue(e.State.Name) != ec2.InstanceStateNameStopped {
+		return time.Time{}, false
+	}
+	m := stoppedReason.FindStringSubmatch(aws.StringValue(e.StateTransitionReason))
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", m[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Type returns the EC2 type
+func (e EC2Instance) Type() string { return "EC2" }
+
+// ID returns the EC2 Instance ID
+func (e EC2Instance) ID() string { return *e.InstanceId }
+
+// Reason returns the state of the EC2 Instance selected for termination
+func (e EC2Instance) Reason() string {
+	if since, ok := e.StoppedSince(); ok {
+		return "selected, stopped since " + since.Format(time.RFC3339)
+	}
+	if e.State != nil {
+		return "selected, " + aws.StringValue(e.State.Name)
+	}
+	return "selected"
+}
+
+// Name returns the EC2 Instance name
+func (e EC2Instance) Name() string {
+	if name := ec2TagValue(e.Tags, "Name"); name != "" {
+		return name
+	}
+	return *e.InstanceId
+}
+
+// Since returns when the EC2Instance was stopped, or launched if it isn't
+// stopped
+func (e EC2Instance) Since(s *session.Session) (time.Time, error) {
+	if since, ok := e.StoppedSince(); ok {
+		return since, nil
+	}
+	return aws.TimeValue(e.LaunchTime), nil
+}
+
+// TagMap returns the tags of the EC2Instance
+func (e EC2Instance) TagMap() map[string]string { return ec2TagMap(e.Tags) }
+
+// References returns the volumes, network interfaces, security groups and
+// EC2-Classic Elastic IP of the EC2Instance
+func (e EC2Instance) References() map[string][]string {
+	refs := make(map[string][]string)
+	for _, m := range e.BlockDeviceMappings {
+		if m.Ebs != nil {
+			refs["ebs"] = append(refs["ebs"], aws.StringValue(m.Ebs.VolumeId))
+		}
+	}
+	for _, ni := range e.NetworkInterfaces {
+		refs["network-interface"] = append(refs["network-interface"], aws.StringValue(ni.NetworkInterfaceId))
+	}
+	for _, g := range e.SecurityGroups {
+		refs["security-group"] = append(refs["security-group"], aws.StringValue(g.GroupId))
+	}
+	if e.PublicIpAddress != nil {
+		refs["eip"] = []string{*e.PublicIpAddress}
+	}
+	return refs
+}
+
+// volumes returns the EBSVolume attached to the EC2Instance, tagged like the
+// instance along with the device they're mounted on. Instance store
+// devices have no volume and are left out.
+func (e EC2Instance) volumes() []EBSVolume {
+	var list []EBSVolume
+	for j := range e.BlockDeviceMappings {
+		if e.BlockDeviceMappings[j].Ebs == nil {
+			continue
+		}
+		ebsVolume := EBSVolume{Volume: &ec2.Volume{}}
+		ebsVolume.VolumeId = e.BlockDeviceMappings[j].Ebs.VolumeId
+		ebsVolume.SetTags(ec2Tags(mergeTags(ec2TagMap(e.Tags), map[string]string{
+			"mount_point": aws.StringValue(e.BlockDeviceMappings[j].DeviceName),
+		})))
+		list = append(list, ebsVolume)
+	}
+	return list
+}
+
+// InstanceSweeteningConfig configures EC2Instance.Sweeten
+type InstanceSweeteningConfig struct {
+	// Snapshots snapshots every volume instead of creating an AMI
+	Snapshots bool `json:"snapshots"`
+	// NoReboot creates the AMI without shutting the instance down first,
+	// the integrity of the file systems isn't guaranteed then
+	NoReboot bool `json:"noReboot"`
+}
+
+// InstanceSweetening is the InstanceSweeteningConfig of EC2Instance.Sweeten,
+// unless the instance has its own, see EC2Instance.WithSweetening
+var InstanceSweetening = InstanceSweeteningConfig{}
+
+// sourceInstanceTag is the tag holding the ID of the instance an AMI
+// was created from
+const sourceInstanceTag = "awsugar:source-instance"
+
+// imageNameInvalid matches the characters AMI names can't contain
+var imageNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9()\[\] ./'@_-]`)
+
+// imageNameMaxLength is the maximum length of AMI names
+const imageNameMaxLength = 128
+
+// createImage creates an AMI of the EC2Instance tagged like the instance,
+// waits for it to be available and returns its ID
+func (e EC2Instance) createImage(ctx aws.Context, s *session.Session, out io.Writer) (string, error) {
+	name := imageNameInvalid.ReplaceAllString(fmt.Sprintf("awsugar %s %s %s", e.Name(),
+		*e.InstanceId, time.Now().UTC().Format("20060102-150405")), "-")
+	if len(name) > imageNameMaxLength {
+		name = name[:imageNameMaxLength]
+	}
+	ec2C := newEC2(s)
+	res, err := ec2C.CreateImageWithContext(ctx, &ec2.CreateImageInput{
+		InstanceId:  e.InstanceId,
+		Name:        aws.String(name),
+		Description: aws.String("Created by awsugar before terminating " + *e.InstanceId),
+		NoReboot:    aws.Bool(e.SweeteningConfig().NoReboot),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Couldn't create AMI of EC2 instance [%s]: %s", e.Name(), err)
+	}
+	tags := ec2Tags(mergeTags(omitTags(ec2TagMap(e.Tags), awsTagKeys), map[string]string{
+		sourceInstanceTag: *e.InstanceId,
+	}))
+	if _, err := ec2C.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+		Resources: []*string{res.ImageId},
+		Tags:      tags,
+	}); err != nil {
+		return "", fmt.Errorf("Couldn't tag AMI [%s]: %s", *res.ImageId, err)
+	}
+	fmt.Fprintf(out, "%s [%s] AMI [%s] created, waiting for it to be available...\n",
+		e.Type(), e.Name(), *res.ImageId)
+	if err := ec2C.WaitUntilImageAvailableWithContext(ctx, &ec2.DescribeImagesInput{
+		ImageIds: []*string{res.ImageId},
+	},
+		request.WithWaiterDelay(request.ConstantWaiterDelay(Waiting.Delay)),
+		request.WithWaiterMaxAttempts(Waiting.MaxAttempts),
+	); err != nil {
+		return "", waitError(ctx, "AMI", *res.ImageId, err)
+	}
+	fmt.Fprintf(out, "AMI [%s] available\n", *res.ImageId)
+	return *res.ImageId, nil
+}
+
+// WithSweetening returns the EC2Instance sweetened as configured by c
+// instead of InstanceSweetening
+func (e EC2Instance) WithSweetening(c InstanceSweeteningConfig) EC2Instance {
+	e.sweetening = &c
+	return e
+}
+
+// SweeteningConfig returns how the EC2Instance is sweetened
+func (e EC2Instance) SweeteningConfig() InstanceSweeteningConfig {
+	if e.sweetening != nil {
+		return *e.sweetening
+	}
+	return InstanceSweetening
+}
+
+// SweetenSteps lists the AMI to create, or the volumes to be snapshotted
+// in snapshot mode
+func (e EC2Instance) SweetenSteps() []string {
+	c := e.SweeteningConfig()
+	if !c.Snapshots {
+		step := "create AMI of " + *e.InstanceId
+		if c.NoReboot {
+			step += " without reboot"
+		}
+		return []string{step}
+	}
+	var steps []string
+	for _, v := range e.volumes() {
+		steps = append(steps, v.SweetenSteps()...)
+	}
+	return steps
+}
+
+// Sweeten creates an AMI of the EC2Instance and returns its ID, or
+// snapshots every volume attached to it in snapshot mode and returns the
+// IDs of the snapshots separated by commas
+func (e EC2Instance) Sweeten(ctx aws.Context, s *session.Session, out io.Writer) (string, error) {
+	if !e.SweeteningConfig().Snapshots {
+		return e.createImage(ctx, s, out)
+	}
+	var snapshots []string
+	for _, v := range e.volumes() {
+		id, err := v.Sweeten(ctx, s, out)
+		if err != nil {
+			return "", err
+		}
+		snapshots = append(snapshots, id)
+	}
+	return strings.Join(snapshots, ","), nil
+}
+
+// Delete the EC2Instance
+func (e EC2Instance) Delete(s *session.Session) error {
+	ec2C := newEC2(s)
+	if _, err := ec2C.TerminateInstances(&ec2.TerminateInstancesInput{
+		InstanceIds: []*string{e.InstanceId},
+	}); err != nil {
+		return fmt.Errorf("Couldn't delete EC2 instance [%s]: %s", e.Name(), err)
+	}
+	return nil
+}
+
+// Recovery returns the AMI created from the EC2Instance by Sweeten along
+// with its launch settings. Without AMI, such as in snapshot mode, the
+// instance can't be launched again and no Recovery is returned.
+func (e EC2Instance) Recovery(s *session.Session, artifact string) (*Recovery, error) {
+	if !strings.HasPrefix(artifact, "ami-") {
+		return nil, nil
+	}
+	r := &InstanceRecovery{
+		ImageID:      artifact,
+		InstanceType: aws.StringValue(e.InstanceType),
+		SubnetID:     aws.StringValue(e.SubnetId),
+		KeyName:      aws.StringValue(e.KeyName),
+		Tags:         omitTags(ec2TagMap(e.Tags), awsTagKeys),
+	}
+	// The availability zone is implied by the subnet in a VPC.
+	if r.SubnetID == "" && e.Placement != nil {
+		r.AvailabilityZone = aws.StringValue(e.Placement.AvailabilityZone)
+	}
+	for _, g := range e.SecurityGroups {
+		r.SecurityGroups = append(r.SecurityGroups, aws.StringValue(g.GroupId))
+	}
+	if e.IamInstanceProfile != nil {
+		r.IamInstanceProfile = aws.StringValue(e.IamInstanceProfile.Arn)
+	}
+	return &Recovery{Instance: r}, nil
+}
+
+// InstanceRecovery holds the AMI created from an EC2Instance and what is
+// needed to launch it the same way
+type InstanceRecovery struct {
+	ImageID            string            `json:"imageId"`
+	InstanceType       string            `json:"instanceType"`
+	SubnetID           string            `json:"subnetId,omitempty"`
+	AvailabilityZone   string            `json:"availabilityZone,omitempty"`
+	SecurityGroups     []string          `json:"securityGroups,omitempty"`
+	KeyName            string            `json:"keyName,omitempty"`
+	IamInstanceProfile string            `json:"iamInstanceProfile,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+}
+
+func (r *InstanceRecovery) restore(s *session.Session) (string, error) {
+	input := &ec2.RunInstancesInput{
+		ImageId:          aws.String(r.ImageID),
+		InstanceType:     aws.String(r.InstanceType),
+		SecurityGroupIds: aws.StringSlice(r.SecurityGroups),
+		MinCount:         aws.Int64(1),
+		MaxCount:         aws.Int64(1),
+	}
+	if r.SubnetID != "" {
+		input.SubnetId = aws.String(r.SubnetID)
+	}
+	if r.AvailabilityZone != "" {
+		input.Placement = &ec2.Placement{AvailabilityZone: aws.String(r.AvailabilityZone)}
+	}
+	if r.KeyName != "" {
+		input.KeyName = aws.String(r.KeyName)
+	}
+	if r.IamInstanceProfile != "" {
+		input.IamInstanceProfile = &ec2.IamInstanceProfileSpecification{Arn: aws.String(r.IamInstanceProfile)}
+	}
+	if tags := ec2Tags(r.Tags); len(tags) > 0 {
+		input.TagSpecifications = []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String(ec2.ResourceTypeInstance),
+				Tags:         tags,
+			},
+		}
+	}
+	ec2C := newEC2(s)
+	res, err := ec2C.RunInstances(input)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't launch EC2 instance from AMI [%s]: %s", r.ImageID, err)
+	}
+	return *res.Instances[0].InstanceId, nil
+}
+
+// waitInstancesTerminated waits for the EC2 instances to be terminated,
+// their volumes, network interfaces and groups are only released then
+func waitInstancesTerminated(ctx aws.Context, s *session.Session, list []Deletable) error {
+	ids := make([]string, 0, len(list))
+	for _, d := range list {
+		ids = append(ids, d.ID())
+	}
+	if err := newEC2(s).WaitUntilInstanceTerminatedWithContext(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: aws.StringSlice(ids),
+	},
+		request.WithWaiterDelay(request.ConstantWaiterDelay(Waiting.Delay)),
+		request.WithWaiterMaxAttempts(Waiting.MaxAttempts),
+	); err != nil {
+		return waitError(ctx, "termination of EC2 instances", strings.Join(ids, ", "), err)
+	}
+	return nil
+}
+
+func init() {
+	RegisterKind(Kind{
+		Name:        "ec2",
+		Aliases:     []string{"instance"},
+		Description: "EC2 instances selected by ID, tag, state or stop date, with an AMI first",
+		List: func(s *session.Session) ([]Deletable, error) {
+			res, err := ListInstances(s, Listing.Instances)
+			return deletables(res), err
+		},
+		Lookups:     map[string]LookupFunc{EC2Instance{}.Type(): lookupInstance},
+		Sweeten:     true,
+		WaitDeleted: waitInstancesTerminated,
+	})
+}
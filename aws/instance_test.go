@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestEC2InstanceSince(t *testing.T) {
+	launched := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		state  string
+		reason string
+		want   time.Time
+	}{
+		{"running", ec2.InstanceStateNameRunning, "", launched},
+		{
+			"stopped", ec2.InstanceStateNameStopped, "User initiated (2018-05-30 12:34:56 GMT)",
+			time.Date(2018, 5, 30, 12, 34, 56, 0, time.UTC),
+		},
+		{"stopped without time", ec2.InstanceStateNameStopped, "Server.ScheduledStop", launched},
+	} {
+		e := EC2Instance{Instance: &ec2.Instance{
+			LaunchTime:            aws.Time(launched),
+			State:                 &ec2.InstanceState{Name: aws.String(tc.state)},
+			StateTransitionReason: aws.String(tc.reason),
+		}}
+		got, err := e.Since(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%s: Since = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWaitInstancesTerminated(t *testing.T) {
+	list := []Deletable{
+		EC2Instance{Instance: &ec2.Instance{InstanceId: aws.String("i-1")}},
+		EC2Instance{Instance: &ec2.Instance{InstanceId: aws.String("i-2")}},
+	}
+	fake := &fakeEC2{}
+	defer useFakeEC2(fake)()
+	if err := waitInstancesTerminated(context.Background(), nil, list); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(fake.terminated, ","); got != "i-1,i-2" {
+		t.Errorf("waited for %s, want i-1,i-2", got)
+	}
+
+	fake.terminateErr = errors.New("exceeded wait attempts")
+	err := waitInstancesTerminated(context.Background(), nil, list)
+	if want := "Couldn't wait for termination of EC2 instances [i-1, i-2]: exceeded wait attempts"; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,56 @@
+package aws
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// Fingerprint returns a digest of the state of the Deletable as described
+// by AWS, any change to the resource changes its fingerprint
+func Fingerprint(d Deletable) (string, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't fingerprint %s [%s]: %s", d.Type(), d.ID(), err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Lookup returns the current state of the Deletable of the given Type and
+// ID, or nil if it doesn't exist anymore, through the lookups of the kinds
+func Lookup(s *session.Session, typ, id string) (Deletable, error) {
+	k, ok := kindsByType[typ]
+	if !ok {
+		return nil, fmt.Errorf("Unknown resource type [%s]", typ)
+	}
+	return k.Lookups[typ](s, id)
+}
+
+func idFilter(name, id string) *ec2.Filter {
+	return &ec2.Filter{Name: aws.String(name), Values: []*string{aws.String(id)}}
+}
+
+// lookupError wraps the error of a lookup, a lookup without error nor
+// result being a resource that doesn't exist anymore
+func lookupError(typ, id string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("Couldn't look up %s [%s]: %s", typ, id, err)
+}
+
+// isNotFound tells if the error is AWS reporting a missing resource
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return strings.Contains(aerr.Code(), "NotFound")
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package aws
+
+import (
+	"fmt"
+)
+
+// Referencer provides an interface for resources whose Describe data
+// references other resources, which can only be cleaned once the
+// referencing resource is gone
+type Referencer interface {
+	// References returns the IDs of the referenced resources by name of
+	// their kind
+	References() map[string][]string
+}
+
+// OrderKinds sorts the kinds so that the kinds referencing others come
+// before them, from the references of the resources listed by kind name.
+// Kinds without references between them, or referencing each other, keep
+// their order.
+func OrderKinds(kinds []Kind, listed map[string][]Deletable) []Kind {
+	names := make([]string, len(kinds))
+	byName := make(map[string]Kind, len(kinds))
+	for i, k := range kinds {
+		names[i] = k.Name
+		byName[k.Name] = k
+	}
+	before := make(map[string]map[string]bool)
+	for _, name := range names {
+		for _, d := range listed[name] {
+			r, ok := d.(Referencer)
+			if !ok {
+				continue
+			}
+			for ref, ids := range r.References() {
+				if ref == name || len(ids) == 0 {
+					continue
+				}
+				if before[name] == nil {
+					before[name] = make(map[string]bool)
+				}
+				before[name][ref] = true
+			}
+		}
+	}
+	ordered := make([]Kind, 0, len(kinds))
+	for _, name := range topoSort(names, before) {
+		ordered = append(ordered, byName[name])
+	}
+	return ordered
+}
+
+// OrderDeletables sorts the resources so that the ones referencing others
+// of the list come before them
+func OrderDeletables(list []Deletable) []Deletable {
+	ids := make([]string, len(list))
+	byID := make(map[string]Deletable, len(list))
+	for i, d := range list {
+		ids[i] = d.ID()
+		byID[d.ID()] = d
+	}
+	before := make(map[string]map[string]bool)
+	for _, d := range list {
+		r, ok := d.(Referencer)
+		if !ok {
+			continue
+		}
+		for _, refs := range r.References() {
+			for _, ref := range refs {
+				if _, ok := byID[ref]; !ok || ref == d.ID() {
+					continue
+				}
+				if before[d.ID()] == nil {
+					before[d.ID()] = make(map[string]bool)
+				}
+				before[d.ID()][ref] = true
+			}
+		}
+	}
+	ordered := make([]Deletable, 0, len(list))
+	for _, id := range topoSort(ids, before) {
+		ordered = append(ordered, byID[id])
+	}
+	return ordered
+}
+
+// topoSort orders the nodes so that each comes before the nodes it has
+// an edge to in before, keeping the given order otherwise. A cycle is
+// broken at its first node in the given order.
+func topoSort(nodes []string, before map[string]map[string]bool) []string {
+	pending := make(map[string]int, len(nodes))
+	for _, n := range nodes {
+		for m := range before[n] {
+			pending[m]++
+		}
+	}
+	done := make(map[string]bool, len(nodes))
+	ordered := make([]string, 0, len(nodes))
+	for len(ordered) < len(nodes) {
+		next := ""
+		for _, n := range nodes {
+			if !done[n] && pending[n] == 0 {
+				next = n
+				break
+			}
+		}
+		if next == "" {
+			// Only cycles remain.
+			for _, n := range nodes {
+				if !done[n] {
+					next = n
+					break
+				}
+			}
+		}
+		done[next] = true
+		ordered = append(ordered, next)
+		for m := range before[next] {
+			pending[m]--
+		}
+	}
+	return ordered
+}
+
+// FilterReferenced moves out of the list the resources referenced by the
+// excluded ones, or by the resources they reference in turn, as they
+// can't be cleaned while the excluded ones remain
+func FilterReferenced(list []Deletable, excluded []Excluded) ([]Deletable, []Excluded) {
+	byID := make(map[string]Deletable, len(list))
+	for _, d := range list {
+		byID[d.ID()] = d
+	}
+	why := make(map[string]string)
+	pending := append([]Excluded(nil), excluded...)
+	for len(pending) > 0 {
+		x := pending[0]
+		pending = pending[1:]
+		r, ok := x.Deletable.(Referencer)
+		if !ok {
+			continue
+		}
+		for _, refs := range r.References() {
+			for _, ref := range refs {
+				d, ok := byID[ref]
+				if !ok || why[ref] != "" {
+					continue
+				}
+				why[ref] = fmt.Sprintf("referenced by %s [%s], which is kept", x.Type(), x.Name())
+				pending = append(pending, Excluded{d, why[ref]})
+			}
+		}
+	}
+	kept := make([]Deletable, 0, len(list))
+	for _, d := range list {
+		if why[d.ID()] != "" {
+			excluded = append(excluded, Excluded{d, why[d.ID()]})
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept, excluded
+}
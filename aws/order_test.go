@@ -0,0 +1,43 @@
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestFilterReferenced(t *testing.T) {
+	group := func(id string, refs ...string) SecurityGroup {
+		sg := &ec2.SecurityGroup{GroupId: aws.String(id)}
+		for _, ref := range refs {
+			sg.IpPermissions = append(sg.IpPermissions, &ec2.IpPermission{
+				UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String(ref)}},
+			})
+		}
+		return SecurityGroup{sg}
+	}
+	list := []Deletable{group("sg-db", "sg-backup"), group("sg-backup"), group("sg-old")}
+	kept, excluded := FilterReferenced(list, []Excluded{{group("sg-protected", "sg-db"), "protected"}})
+
+	var got []string
+	for _, d := range kept {
+		got = append(got, d.ID())
+	}
+	if want := []string{"sg-old"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+	got = nil
+	for _, x := range excluded {
+		got = append(got, x.ID()+": "+x.Why)
+	}
+	want := []string{
+		"sg-protected: protected",
+		"sg-db: referenced by Security Group [sg-protected], which is kept",
+		"sg-backup: referenced by Security Group [sg-db], which is kept",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("excluded %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+)
+
+// eachPage calls fetch with the token of the page to retrieve, starting
+// with a nil token, until fetch returns an empty token for the next page.
+// It is meant for the operations the SDK provides no *Pages function for.
+func eachPage(fetch func(token *string) (next *string, err error)) error {
+	var token *string
+	for {
+		next, err := fetch(token)
+		if err != nil {
+			return err
+		}
+		if aws.StringValue(next) == "" {
+			return nil
+		}
+		token = next
+	}
+}
+
+// The describe helpers below return every page of the Describe calls
+// the List functions rely on.
+
+func describeInstances(s *session.Session, input *ec2.DescribeInstancesInput) ([]*ec2.Instance, error) {
+	var list []*ec2.Instance
+	err := newEC2(s).DescribeInstancesPages(input,
+		func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+			for _, r := range page.Reservations {
+				list = append(list, r.Instances...)
+			}
+			return true
+		})
+	return list, err
+}
+
+func describeVolumes(s *session.Session, input *ec2.DescribeVolumesInput) ([]*ec2.Volume, error) {
+	var list []*ec2.Volume
+	err := newEC2(s).DescribeVolumesPages(input,
+		func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+			list = append(list, page.Volumes...)
+			return true
+		})
+	return list, err
+}
+
+func describeSnapshots(s *session.Session, input *ec2.DescribeSnapshotsInput) ([]*ec2.Snapshot, error) {
+	var list []*ec2.Snapshot
+	err := newEC2(s).DescribeSnapshotsPages(input,
+		func(page *ec2.DescribeSnapshotsOutput, lastPage bool) bool {
+			list = append(list, page.Snapshots...)
+			return true
+		})
+	return list, err
+}
+
+func describeSecurityGroups(s *session.Session, input *ec2.DescribeSecurityGroupsInput) ([]*ec2.SecurityGroup, error) {
+	var list []*ec2.SecurityGroup
+	ec2C := newEC2(s)
+	err := eachPage(func(token *string) (*string, error) {
+		input.NextToken = token
+		res, err := ec2C.DescribeSecurityGroups(input)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, res.SecurityGroups...)
+		return res.NextToken, nil
+	})
+	return list, err
+}
+
+// describeNetworkInterfaces returns the network interfaces in a single
+// call, DescribeNetworkInterfaces isn't paginated in this API version.
+func describeNetworkInterfaces(s *session.Session, input *ec2.DescribeNetworkInterfacesInput) ([]*ec2.NetworkInterface, error) {
+	res, err := newEC2(s).DescribeNetworkInterfaces(input)
+	if err != nil {
+		return nil, err
+	}
+	return res.NetworkInterfaces, nil
+}
+
+func describeLaunchTemplates(s *session.Session, input *ec2.DescribeLaunchTemplatesInput) ([]*ec2.LaunchTemplate, error) {
+	var list []*ec2.LaunchTemplate
+	ec2C := newEC2(s)
+	err := eachPage(func(token *string) (*string, error) {
+		input.NextToken = token
+		res, err := ec2C.DescribeLaunchTemplates(input)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, res.LaunchTemplates...)
+		return res.NextToken, nil
+	})
+	return list, err
+}
+
+func describeLaunchTemplateVersions(s *session.Session, input *ec2.DescribeLaunchTemplateVersionsInput) ([]*ec2.LaunchTemplateVersion, error) {
+	var list []*ec2.LaunchTemplateVersion
+	ec2C := newEC2(s)
+	err := eachPage(func(token *string) (*string, error) {
+		input.NextToken = token
+		res, err := ec2C.DescribeLaunchTemplateVersions(input)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, res.LaunchTemplateVersions...)
+		return res.NextToken, nil
+	})
+	return list, err
+}
+
+func describeLoadBalancers(s *session.Session) ([]*elb.LoadBalancerDescription, error) {
+	var list []*elb.LoadBalancerDescription
+	err := elb.New(s).DescribeLoadBalancersPages(&elb.DescribeLoadBalancersInput{},
+		func(page *elb.DescribeLoadBalancersOutput, lastPage bool) bool {
+			list = append(list, page.LoadBalancerDescriptions...)
+			return true
+		})
+	return list, err
+}
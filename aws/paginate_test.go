@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestEachPage(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	for _, tc := range []struct {
+		name   string
+		pages  int
+		failAt int
+		tokens string
+		err    error
+	}{
+		{name: "single page", pages: 1, failAt: -1, tokens: ""},
+		{name: "several pages", pages: 3, failAt: -1, tokens: ",1,2"},
+		{name: "error", pages: 3, failAt: 1, tokens: ",1", err: errFetch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var tokens []string
+			err := eachPage(func(token *string) (*string, error) {
+				tokens = append(tokens, aws.StringValue(token))
+				i, _ := strconv.Atoi(aws.StringValue(token))
+				if i == tc.failAt {
+					return nil, errFetch
+				}
+				if i+1 == tc.pages {
+					return aws.String(""), nil
+				}
+				return aws.String(strconv.Itoa(i + 1)), nil
+			})
+			if err != tc.err {
+				t.Errorf("err = %v, want %v", err, tc.err)
+			}
+			if got := strings.Join(tokens, ","); got != tc.tokens {
+				t.Errorf("tokens = %q, want %q", got, tc.tokens)
+			}
+		})
+	}
+}
+
+func TestDescribeSecurityGroups(t *testing.T) {
+	group := func(id string) *ec2.SecurityGroup { return &ec2.SecurityGroup{GroupId: aws.String(id)} }
+	fake := &fakeEC2{securityGroupPages: [][]*ec2.SecurityGroup{
+		{group("sg-1"), group("sg-2")},
+		{},
+		{group("sg-3")},
+	}}
+	defer useFakeEC2(fake)()
+	list, err := describeSecurityGroups(nil, &ec2.DescribeSecurityGroupsInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, sg := range list {
+		ids = append(ids, *sg.GroupId)
+	}
+	if got, want := strings.Join(ids, ","), "sg-1,sg-2,sg-3"; got != want {
+		t.Errorf("groups = %s, want %s", got, want)
+	}
+	if got, want := strings.Join(fake.tokens, ","), ",1,2"; got != want {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+}
+
+func TestDescribeInstances(t *testing.T) {
+	instance := func(id string) *ec2.Instance { return &ec2.Instance{InstanceId: aws.String(id)} }
+	fake := &fakeEC2{instancePages: [][]*ec2.Instance{
+		{instance("i-1"), instance("i-2")},
+		{},
+		{instance("i-3")},
+	}}
+	defer useFakeEC2(fake)()
+	list, err := describeInstances(nil, &ec2.DescribeInstancesInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, i := range list {
+		ids = append(ids, *i.InstanceId)
+	}
+	if got, want := strings.Join(ids, ","), "i-1,i-2,i-3"; got != want {
+		t.Errorf("instances = %s, want %s", got, want)
+	}
+	if got, want := strings.Join(fake.tokens, ","), ",1,2"; got != want {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+}
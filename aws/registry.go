@@ -0,0 +1,118 @@
+package aws
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// LookupFunc returns the resource of the given ID, nil if it doesn't
+// exist anymore
+type LookupFunc func(s *session.Session, id string) (Deletable, error)
+
+// Kind is a kind of resource clean knows how to find and delete. Each kind
+// lives in a single file registering it, along with its resources.
+type Kind struct {
+	Name        string
+	Aliases     []string
+	Description string
+	// List returns the resources of the kind to clean in the region
+	List func(*session.Session) ([]Deletable, error)
+	// Lookups look up the resources of the kind by ID, keyed by the Type
+	// of the resources, for apply
+	Lookups map[string]LookupFunc
+	// Tags returns the tags of the listed resources by ID, for resources
+	// that aren't Tagged as they're listed without their tags
+	Tags func(*session.Session, []Deletable) (map[string]map[string]string, error)
+	// Sweeten tells if the resources are sweetened before deletion, unless
+	// sweetening is turned off
+	Sweeten bool
+	// Batch sweetens every resource before the first deletion, whether
+	// sweetening is turned off or not, for resources whose sweetening
+	// unblocks the deletion of the others
+	Batch bool
+	// WaitDeleted waits for the deleted resources to be gone, for kinds
+	// whose resources hold others for a while after their deletion
+	WaitDeleted func(aws.Context, *session.Session, []Deletable) error
+}
+
+// ListConfig configures the listers of the kinds needing more than a
+// session
+type ListConfig struct {
+	// Instances selects the EC2 instances to clean
+	Instances InstanceSelector
+	// LaunchTemplates adds the outdated launch template versions to the
+	// launch configurations
+	LaunchTemplates bool
+}
+
+// Listing is the ListConfig of the listers
+var Listing ListConfig
+
+// kinds are the registered kinds by name and alias
+var kinds = make(map[string]*Kind)
+
+// kindsByType are the registered kinds by Type of their resources
+var kindsByType = make(map[string]*Kind)
+
+// RegisterKind makes the kind available to clean under its name and
+// aliases. It panics if one of them, or one of the types of its
+// resources, is already taken.
+func RegisterKind(k Kind) {
+	for _, name := range append([]string{k.Name}, k.Aliases...) {
+		if _, ok := kinds[name]; ok {
+			panic(fmt.Sprintf("Kind [%s] registered twice", name))
+		}
+		kinds[name] = &k
+	}
+	for typ := range k.Lookups {
+		if _, ok := kindsByType[typ]; ok {
+			panic(fmt.Sprintf("Type [%s] registered twice", typ))
+		}
+		kindsByType[typ] = &k
+	}
+}
+
+// LookupKind returns the kind of the given name or alias
+func LookupKind(name string) (Kind, bool) {
+	k, ok := kinds[name]
+	if !ok {
+		return Kind{}, false
+	}
+	return *k, true
+}
+
+// KindOfType returns the kind listing the resources of the given Type
+func KindOfType(typ string) (Kind, bool) {
+	k, ok := kindsByType[typ]
+	if !ok {
+		return Kind{}, false
+	}
+	return *k, true
+}
+
+// Kinds returns the registered kinds sorted by name
+func Kinds() []Kind {
+	var list []Kind
+	for name, k := range kinds {
+		if name == k.Name {
+			list = append(list, *k)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// deletables converts a slice of Deletable implementations, such as
+// []EBSVolume, to a []Deletable
+func deletables(slice interface{}) []Deletable {
+	v := reflect.ValueOf(slice)
+	list := make([]Deletable, v.Len())
+	for i := range list {
+		list[i] = v.Index(i).Interface().(Deletable)
+	}
+	return list
+}
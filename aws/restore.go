@@ -0,0 +1,60 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Recoverable provides an interface for resources that can be recreated
+// once deleted
+type Recoverable interface {
+	// Recovery returns what is needed to recreate the resource. It is
+	// called after sweetening, right before the deletion, with the
+	// artifact returned by Sweeten if any.
+	Recovery(s *session.Session, artifact string) (*Recovery, error)
+}
+
+// Recovery holds what is needed to recreate a deleted resource, only the
+// field matching the type of the resource is set
+type Recovery struct {
+	Instance         *InstanceRecovery         `json:"instance,omitempty"`
+	Volume           *VolumeRecovery           `json:"volume,omitempty"`
+	LoadBalancer     *LoadBalancerRecovery     `json:"loadBalancer,omitempty"`
+	NetworkInterface *NetworkInterfaceRecovery `json:"networkInterface,omitempty"`
+	Address          *AddressRecovery          `json:"address,omitempty"`
+}
+
+// Artifact returns the ID of what sweetening left to recreate the resource
+// from, the AMI of an instance or the snapshot of a volume, or the public
+// IP of an Elastic IP to reclaim, if any
+func (r *Recovery) Artifact() string {
+	switch {
+	case r.Instance != nil:
+		return r.Instance.ImageID
+	case r.Volume != nil:
+		return r.Volume.SnapshotID
+	case r.Address != nil:
+		return r.Address.PublicIP
+	}
+	return ""
+}
+
+// Restore recreates the resource described by the Recovery and returns
+// the ID of the new resource. name is the name of the deleted resource,
+// needed for load balancers.
+func (r *Recovery) Restore(s *session.Session, name string) (string, error) {
+	switch {
+	case r.Instance != nil:
+		return r.Instance.restore(s)
+	case r.Volume != nil:
+		return r.Volume.restore(s)
+	case r.LoadBalancer != nil:
+		return r.LoadBalancer.restore(s, name)
+	case r.NetworkInterface != nil:
+		return r.NetworkInterface.restore(s)
+	case r.Address != nil:
+		return r.Address.restore(s)
+	}
+	return "", fmt.Errorf("Nothing to restore [%s] from", name)
+}
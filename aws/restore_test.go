@@ -0,0 +1,37 @@
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestRecoveryOmitsReservedTags(t *testing.T) {
+	tags := ec2TagList("Name", "data", "aws:cloudformation:stack-name", "web")
+	want := map[string]string{"Name": "data"}
+	v := EBSVolume{Volume: &ec2.Volume{VolumeId: aws.String("vol-1"), Iops: aws.Int64(3000), Tags: tags}}
+	ni := NetworkInterface{NetworkInterface: &ec2.NetworkInterface{TagSet: tags}}
+	eip := ElasticIP{Address: &ec2.Address{Tags: tags}}
+	for _, tc := range []struct {
+		name string
+		r    Recoverable
+		tags func(*Recovery) map[string]string
+	}{
+		{"volume", v, func(r *Recovery) map[string]string { return r.Volume.Tags }},
+		{"network interface", ni, func(r *Recovery) map[string]string { return r.NetworkInterface.Tags }},
+		{"elastic IP", eip, func(r *Recovery) map[string]string { return r.Address.Tags }},
+	} {
+		r, err := tc.r.Recovery(nil, "snap-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tc.tags(r); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: tags = %v, want %v", tc.name, got, want)
+		}
+	}
+	if r, _ := v.Recovery(nil, "snap-1"); r.Volume.Iops != 3000 {
+		t.Errorf("volume IOPS = %d, want 3000", r.Volume.Iops)
+	}
+}
@@ -0,0 +1,507 @@
+package aws
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/route53"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// IPOwner describes a resource owning or pointing to an IP
+type IPOwner struct {
+	IP         string
+	Type       string
+	ID         string
+	Name       string
+	HostedZone string
+}
+
+// SearchIPs resolves every IP to the regional resources that own it or
+// point to it: network interfaces, Elastic IPs, instances and load
+// balancers. Services failing to answer don't prevent the others from
+// being searched, their errors are returned alongside the results.
+func SearchIPs(s *session.Session, ips []net.IP) ([]IPOwner, error) {
+	wanted, values := ipSets(ips)
+	var list []IPOwner
+	var retErr *multierror.Error
+	for _, search := range []func() ([]IPOwner, error){
+		func() ([]IPOwner, error) { return searchNetworkInterfaces(s, values) },
+		func() ([]IPOwner, error) { return searchAddresses(s, values) },
+		func() ([]IPOwner, error) { return searchInstances(s, values) },
+		func() ([]IPOwner, error) { return searchLoadBalancers(s, wanted) },
+	} {
+		res, err := search()
+		if err != nil {
+			retErr = multierror.Append(retErr, err)
+		}
+		list = append(list, res...)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].IP < list[j].IP })
+	return list, retErr.ErrorOrNil()
+}
+
+// SearchRoute53 resolves every IP to the Route53 record sets pointing to
+// it, either directly through A records or through an alias target.
+// Route53 being global, it doesn't depend on the region of the session.
+func SearchRoute53(s *session.Session, ips []net.IP) ([]IPOwner, error) {
+	wanted, _ := ipSets(ips)
+	list, err := searchRoute53(s, wanted)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].IP < list[j].IP })
+	return list, err
+}
+
+func ipSets(ips []net.IP) (map[string]bool, []*string) {
+	wanted := make(map[string]bool, len(ips))
+	values := make([]*string, 0, len(ips))
+	for _, ip := range ips {
+		wanted[ip.String()] = true
+		values = append(values, aws.String(ip.String()))
+	}
+	return wanted, values
+}
+
+// lookupIPs resolves a DNS name, errors are ignored as a dangling
+// name simply can't match any IP
+func lookupIPs(host string) []string {
+	ips, _ := net.LookupIP(strings.TrimSuffix(host, "."))
+	list := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		list = append(list, ip.String())
+	}
+	return list
+}
+
+func searchRoute53(s *session.Session, wanted map[string]bool) ([]IPOwner, error) {
+	r53C := route53.New(s)
+	var zones []*route53.HostedZone
+	if err := r53C.ListHostedZonesPages(&route53.ListHostedZonesInput{},
+		func(page *route53.ListHostedZonesOutput, lastPage bool) bool {
+			zones = append(zones, page.HostedZones...)
+			return true
+		}); err != nil {
+		return nil, fmt.Errorf("Couldn't list hosted zones: %s", err)
+	}
+	var list []IPOwner
+	for _, z := range zones {
+		zone := fmt.Sprintf("%s (%s)", *z.Name, strings.TrimPrefix(*z.Id, "/hostedzone/"))
+		if err := r53C.ListResourceRecordSetsPages(&route53.ListResourceRecordSetsInput{
+			HostedZoneId: z.Id,
+		}, func(page *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
+			for _, rs := range page.ResourceRecordSets {
+				if rs.AliasTarget != nil {
+					for _, ip := range lookupIPs(*rs.AliasTarget.DNSName) {
+						if wanted[ip] {
+							list = append(list, IPOwner{ip, "Route53 Alias", *rs.Name,
+								*rs.AliasTarget.DNSName, zone})
+						}
+					}
+					continue
+				}
+				if *rs.Type != route53.RRTypeA && *rs.Type != route53.RRTypeAaaa {
+					continue
+				}
+				for _, rr := range rs.ResourceRecords {
+					if ip := net.ParseIP(aws.StringValue(rr.Value)); ip != nil && wanted[ip.String()] {
+						list = append(list, IPOwner{ip.String(), "Route53 " + *rs.Type,
+							*rs.Name, *rs.Name, zone})
+					}
+				}
+			}
+			return true
+		}); err != nil {
+			return list, fmt.Errorf("Couldn't list record sets of hosted zone [%s]: %s", *z.Id, err)
+		}
+	}
+	return list, nil
+}
+
+func searchNetworkInterfaces(s *session.Session, values []*string) ([]IPOwner, error) {
+	var list []IPOwner
+	for _, filter := range []string{"addresses.private-ip-address", "association.public-ip"} {
+		res, err := describeNetworkInterfaces(s, &ec2.DescribeNetworkInterfacesInput{
+			Filters: []*ec2.Filter{{Name: aws.String(filter), Values: values}},
+		})
+		if err != nil {
+			return list, fmt.Errorf("Couldn't search network interfaces: %s", err)
+		}
+		for _, ni := range res {
+			name := aws.StringValue(ni.Description)
+			for _, pip := range ni.PrivateIpAddresses {
+				ip := aws.StringValue(pip.PrivateIpAddress)
+				if filter == "association.public-ip" {
+					if pip.Association == nil {
+						continue
+					}
+					ip = aws.StringValue(pip.Association.PublicIp)
+				}
+				if containsValue(values, ip) {
+					list = append(list, IPOwner{ip, "Network Interface",
+						*ni.NetworkInterfaceId, name, ""})
+				}
+			}
+		}
+	}
+	return list, nil
+}
+
+func searchAddresses(s *session.Session, values []*string) ([]IPOwner, error) {
+	ec2C := newEC2(s)
+	res, err := ec2C.DescribeAddresses(&ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("public-ip"), Values: values}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't search elastic IPs: %s", err)
+	}
+	list := make([]IPOwner, 0, len(res.Addresses))
+	for _, a := range res.Addresses {
+		id := aws.StringValue(a.AllocationId)
+		if id == "" {
+			id = *a.PublicIp
+		}
+		list = append(list, IPOwner{*a.PublicIp, "EIP", id, ec2TagValue(a.Tags, "Name"), ""})
+	}
+	return list, nil
+}
+
+func searchInstances(s *session.Session, values []*string) ([]IPOwner, error) {
+	found := make(map[string]*ec2.Instance)
+	for _, filter := range []string{"network-interface.addresses.private-ip-address", "ip-address"} {
+		res, err := describeInstances(s, &ec2.DescribeInstancesInput{
+			Filters: []*ec2.Filter{{Name: aws.String(filter), Values: values}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't search instances: %s", err)
+		}
+		for _, is := range res {
+			found[*is.InstanceId] = is
+		}
+	}
+	var list []IPOwner
+	for _, is := range found {
+		ips := []*string{is.PrivateIpAddress, is.PublicIpAddress}
+		for _, ni := range is.NetworkInterfaces {
+			for _, pip := range ni.PrivateIpAddresses {
+				if !aws.BoolValue(pip.Primary) {
+					ips = append(ips, pip.PrivateIpAddress)
+				}
+			}
+		}
+		for _, ip := range ips {
+			if ip != nil && containsValue(values, *ip) {
+				list = append(list, IPOwner{*ip, "EC2", *is.InstanceId,
+					ec2TagValue(is.Tags, "Name"), ""})
+			}
+		}
+	}
+	return list, nil
+}
+
+func searchLoadBalancers(s *session.Session, wanted map[string]bool) ([]IPOwner, error) {
+	res, err := describeLoadBalancers(s)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't search load balancers: %s", err)
+	}
+	var list []IPOwner
+	for _, lb := range res {
+		for _, ip := range lookupIPs(aws.StringValue(lb.DNSName)) {
+			if wanted[ip] {
+				list = append(list, IPOwner{ip, "ELB", *lb.LoadBalancerName, *lb.DNSName, ""})
+			}
+		}
+	}
+	return list, nil
+}
+
+func containsValue(values []*string, v string) bool {
+	for i := range values {
+		if aws.StringValue(values[i]) == v {
+			return true
+		}
+	}
+	return false
+}
+
+// TagFilter selects resources by tag. An empty Value matches any value
+// of the Key, otherwise Value can contain * wildcards.
+type TagFilter struct {
+	Key   string
+	Value string
+}
+
+// ParseTagFilter parses a filter of the form key=value, key=val* or key
+func ParseTagFilter(s string) (TagFilter, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if parts[0] == "" {
+		return TagFilter{}, fmt.Errorf("Invalid tag filter [%s]: missing key", s)
+	}
+	f := TagFilter{Key: parts[0]}
+	if len(parts) == 2 {
+		f.Value = parts[1]
+	}
+	return f, nil
+}
+
+// String returns the TagFilter in the form ParseTagFilter parses
+func (f TagFilter) String() string {
+	if f.Value == "" {
+		return f.Key
+	}
+	return f.Key + "=" + f.Value
+}
+
+func (f TagFilter) hasWildcard() bool { return strings.Contains(f.Value, "*") }
+
+// Match tells if the tags contain the key with a value matching the filter
+func (f TagFilter) Match(tags map[string]string) bool {
+	v, ok := tags[f.Key]
+	if !ok {
+		return false
+	}
+	if f.Value == "" {
+		return true
+	}
+	return globMatch(f.Value, v)
+}
+
+// ec2Filter returns the EC2 Describe filter equivalent to the TagFilter
+func (f TagFilter) ec2Filter() *ec2.Filter {
+	if f.Value == "" {
+		return &ec2.Filter{Name: aws.String("tag-key"), Values: []*string{aws.String(f.Key)}}
+	}
+	return &ec2.Filter{Name: aws.String("tag:" + f.Key), Values: []*string{aws.String(f.Value)}}
+}
+
+// TaggedResource is a resource found through its tags
+type TaggedResource struct {
+	Type string
+	ID   string
+	Name string
+	Tags map[string]string
+}
+
+// taggingResourceTypes maps the Resource Groups Tagging API resource
+// types to the types used through awsugar
+var taggingResourceTypes = map[string]string{
+	"ec2:instance":                      "EC2",
+	"ec2:volume":                        "EBS",
+	"ec2:snapshot":                      "Snapshot",
+	"ec2:network-interface":             "Network Interface",
+	"ec2:security-group":                "Security Group",
+	"elasticloadbalancing:loadbalancer": "ELB",
+}
+
+// SearchTags returns the instances, volumes, snapshots, network interfaces,
+// load balancers and security groups matching all the filters. The Resource
+// Groups Tagging API is used when none of the filters has a wildcard, it
+// falls back to the Describe calls of every service otherwise or if the
+// Tagging API can't be used.
+func SearchTags(s *session.Session, filters []TagFilter) ([]TaggedResource, error) {
+	useTaggingAPI := true
+	for _, f := range filters {
+		if f.hasWildcard() {
+			useTaggingAPI = false
+		}
+	}
+	if useTaggingAPI {
+		if list, err := searchTaggingAPI(s, filters); err == nil {
+			return list, nil
+		}
+	}
+	var list []TaggedResource
+	var retErr *multierror.Error
+	for _, search := range []func(*session.Session, []TagFilter) ([]TaggedResource, error){
+		searchTaggedInstances,
+		searchTaggedVolumes,
+		searchTaggedSnapshots,
+		searchTaggedNetworkInterfaces,
+		searchTaggedSecurityGroups,
+		searchTaggedLoadBalancers,
+	} {
+		res, err := search(s, filters)
+		if err != nil {
+			retErr = multierror.Append(retErr, err)
+		}
+		list = append(list, res...)
+	}
+	return list, retErr.ErrorOrNil()
+}
+
+func searchTaggingAPI(s *session.Session, filters []TagFilter) ([]TaggedResource, error) {
+	input := &resourcegroupstaggingapi.GetResourcesInput{}
+	for rt := range taggingResourceTypes {
+		input.ResourceTypeFilters = append(input.ResourceTypeFilters, aws.String(rt))
+	}
+	for _, f := range filters {
+		tf := &resourcegroupstaggingapi.TagFilter{Key: aws.String(f.Key)}
+		if f.Value != "" {
+			tf.Values = []*string{aws.String(f.Value)}
+		}
+		input.TagFilters = append(input.TagFilters, tf)
+	}
+	var list []TaggedResource
+	rgtC := resourcegroupstaggingapi.New(s)
+	if err := rgtC.GetResourcesPages(input,
+		func(page *resourcegroupstaggingapi.GetResourcesOutput, lastPage bool) bool {
+			for _, m := range page.ResourceTagMappingList {
+				tags := make(map[string]string, len(m.Tags))
+				for _, t := range m.Tags {
+					tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+				}
+				r := TaggedResource{ID: aws.StringValue(m.ResourceARN), Tags: tags}
+				// arn:partition:service:region:account:type/id
+				if parts := strings.SplitN(r.ID, ":", 6); len(parts) == 6 {
+					resource := strings.SplitN(parts[5], "/", 2)
+					r.Type = taggingResourceTypes[parts[2]+":"+resource[0]]
+					if len(resource) == 2 {
+						r.ID = resource[1]
+					}
+				}
+				r.Name = tags["Name"]
+				list = append(list, r)
+			}
+			return true
+		}); err != nil {
+		return nil, fmt.Errorf("Couldn't search resources by tag: %s", err)
+	}
+	return list, nil
+}
+
+func ec2TagFilters(filters []TagFilter) []*ec2.Filter {
+	list := make([]*ec2.Filter, 0, len(filters))
+	for _, f := range filters {
+		list = append(list, f.ec2Filter())
+	}
+	return list
+}
+
+func searchTaggedInstances(s *session.Session, filters []TagFilter) ([]TaggedResource, error) {
+	res, err := describeInstances(s, &ec2.DescribeInstancesInput{Filters: ec2TagFilters(filters)})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't search instances: %s", err)
+	}
+	list := make([]TaggedResource, 0, len(res))
+	for _, is := range res {
+		list = append(list, TaggedResource{"EC2", *is.InstanceId,
+			ec2TagValue(is.Tags, "Name"), ec2TagMap(is.Tags)})
+	}
+	return list, nil
+}
+
+func searchTaggedVolumes(s *session.Session, filters []TagFilter) ([]TaggedResource, error) {
+	res, err := describeVolumes(s, &ec2.DescribeVolumesInput{Filters: ec2TagFilters(filters)})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't search EBS volumes: %s", err)
+	}
+	list := make([]TaggedResource, 0, len(res))
+	for _, v := range res {
+		list = append(list, TaggedResource{"EBS", *v.VolumeId,
+			ec2TagValue(v.Tags, "Name"), ec2TagMap(v.Tags)})
+	}
+	return list, nil
+}
+
+func searchTaggedSnapshots(s *session.Session, filters []TagFilter) ([]TaggedResource, error) {
+	res, err := describeSnapshots(s, &ec2.DescribeSnapshotsInput{
+		OwnerIds: []*string{aws.String("self")},
+		Filters:  ec2TagFilters(filters),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't search snapshots: %s", err)
+	}
+	list := make([]TaggedResource, 0, len(res))
+	for _, snap := range res {
+		list = append(list, TaggedResource{"Snapshot", *snap.SnapshotId,
+			ec2TagValue(snap.Tags, "Name"), ec2TagMap(snap.Tags)})
+	}
+	return list, nil
+}
+
+func searchTaggedNetworkInterfaces(s *session.Session, filters []TagFilter) ([]TaggedResource, error) {
+	res, err := describeNetworkInterfaces(s, &ec2.DescribeNetworkInterfacesInput{
+		Filters: ec2TagFilters(filters),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't search network interfaces: %s", err)
+	}
+	list := make([]TaggedResource, 0, len(res))
+	for _, ni := range res {
+		list = append(list, TaggedResource{"Network Interface", *ni.NetworkInterfaceId,
+			ec2TagValue(ni.TagSet, "Name"), ec2TagMap(ni.TagSet)})
+	}
+	return list, nil
+}
+
+func searchTaggedSecurityGroups(s *session.Session, filters []TagFilter) ([]TaggedResource, error) {
+	res, err := describeSecurityGroups(s, &ec2.DescribeSecurityGroupsInput{
+		Filters: ec2TagFilters(filters),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't search security groups: %s", err)
+	}
+	list := make([]TaggedResource, 0, len(res))
+	for _, sg := range res {
+		list = append(list, TaggedResource{"Security Group", *sg.GroupId,
+			aws.StringValue(sg.GroupName), ec2TagMap(sg.Tags)})
+	}
+	return list, nil
+}
+
+// elbTagsBatchSize is the maximum number of load balancers DescribeTags accepts
+const elbTagsBatchSize = 20
+
+// searchTaggedLoadBalancers matches the tags locally as ELB has no
+// Describe filters
+func searchTaggedLoadBalancers(s *session.Session, filters []TagFilter) ([]TaggedResource, error) {
+	res, err := describeLoadBalancers(s)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't search load balancers: %s", err)
+	}
+	names := make([]*string, 0, len(res))
+	for _, lb := range res {
+		names = append(names, lb.LoadBalancerName)
+	}
+	lbTags, err := loadBalancerTags(s, names)
+	if err != nil {
+		return nil, err
+	}
+	var list []TaggedResource
+	for _, name := range names {
+		tags := lbTags[*name]
+		matched := true
+		for _, f := range filters {
+			matched = matched && f.Match(tags)
+		}
+		if matched {
+			list = append(list, TaggedResource{"ELB", *name, *name, tags})
+		}
+	}
+	return list, nil
+}
+
+// loadBalancerTags returns the tags of the load balancers by name
+func loadBalancerTags(s *session.Session, names []*string) (map[string]map[string]string, error) {
+	elbC := elb.New(s)
+	tagsByName := make(map[string]map[string]string, len(names))
+	for start := 0; start < len(names); start += elbTagsBatchSize {
+		end := start + elbTagsBatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+		res, err := elbC.DescribeTags(&elb.DescribeTagsInput{LoadBalancerNames: names[start:end]})
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't describe load balancers tags: %s", err)
+		}
+		for _, desc := range res.TagDescriptions {
+			tagsByName[*desc.LoadBalancerName] = elbTagMap(desc.Tags)
+		}
+	}
+	return tagsByName, nil
+}
@@ -0,0 +1,279 @@
+package aws
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// SecurityGroup is a proxy for the AWS framework struct
+type SecurityGroup struct {
+	*ec2.SecurityGroup
+}
+
+var _ = Deletable(&SecurityGroup{})
+var _ = Sweetener(&SecurityGroup{})
+var _ = Aged(&SecurityGroup{})
+var _ = Tagged(&SecurityGroup{})
+var _ = Referencer(&SecurityGroup{})
+
+// ListUnusedSecurityGroups returns a list of SecurityGroup that are not
+// referenced by any network interface, instance, launch configuration,
+// launch template version or by the rules of a SecurityGroup kept. Default
+// groups are never returned as they can't be deleted.
+func ListUnusedSecurityGroups(s *session.Session) ([]SecurityGroup, error) {
+	res, err := describeSecurityGroups(s, &ec2.DescribeSecurityGroupsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list security groups: %s", err)
+	}
+	used := make(map[string]bool)
+	markUsed := func(groups []*ec2.GroupIdentifier) {
+		for _, g := range groups {
+			used[aws.StringValue(g.GroupId)] = true
+		}
+	}
+
+	niRes, err := describeNetworkInterfaces(s, &ec2.DescribeNetworkInterfacesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list network interfaces: %s", err)
+	}
+	for _, ni := range niRes {
+		markUsed(ni.Groups)
+	}
+
+	// EC2-Classic instances don't have network interfaces.
+	iRes, err := describeInstances(s, &ec2.DescribeInstancesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list instances: %s", err)
+	}
+	for _, is := range iRes {
+		markUsed(is.SecurityGroups)
+	}
+
+	lcs, err := describeLaunchConfigurations(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, lc := range lcs {
+		// Launch configurations may reference groups either by ID or by name.
+		for _, g := range lc.SecurityGroups {
+			used[aws.StringValue(g)] = true
+		}
+	}
+
+	// Any version of a launch template can still be launched, by an auto
+	// scaling group or by hand.
+	lts, err := describeLaunchTemplates(s, &ec2.DescribeLaunchTemplatesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list launch templates: %s", err)
+	}
+	for _, lt := range lts {
+		versions, err := describeLaunchTemplateVersions(s, &ec2.DescribeLaunchTemplateVersionsInput{
+			LaunchTemplateId: lt.LaunchTemplateId,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't list versions of launch template [%s]: %s",
+				*lt.LaunchTemplateId, err)
+		}
+		for _, v := range versions {
+			for _, g := range launchTemplateGroups(v.LaunchTemplateData) {
+				used[g] = true
+			}
+		}
+	}
+
+	return unusedGroups(res, used), nil
+}
+
+// unusedGroups returns the groups neither used nor referenced by the rules
+// of a kept group, default and used groups being kept
+func unusedGroups(groups []*ec2.SecurityGroup, used map[string]bool) []SecurityGroup {
+	kept := func(sg *ec2.SecurityGroup) bool {
+		return aws.StringValue(sg.GroupName) == "default" ||
+			used[aws.StringValue(sg.GroupId)] || used[aws.StringValue(sg.GroupName)]
+	}
+	// A group referenced by the rules of a kept group is kept as well,
+	// follow the references until nothing changes.
+	for changed := true; changed; {
+		changed = false
+		for _, sg := range groups {
+			if !kept(sg) {
+				continue
+			}
+			for _, ref := range groupReferences(sg) {
+				if !used[ref] {
+					used[ref] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	list := make([]SecurityGroup, 0, len(groups))
+	for _, sg := range groups {
+		if !kept(sg) {
+			list = append(list, SecurityGroup{sg})
+		}
+	}
+	return list
+}
+
+// lookupSecurityGroup returns the SecurityGroup of the given ID
+func lookupSecurityGroup(s *session.Session, id string) (Deletable, error) {
+	res, err := describeSecurityGroups(s, &ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{idFilter("group-id", id)},
+	})
+	if err != nil || len(res) == 0 {
+		return nil, lookupError(SecurityGroup{}.Type(), id, err)
+	}
+	return SecurityGroup{res[0]}, nil
+}
+
+// launchTemplateGroups returns the IDs and names of the groups the
+// instances and network interfaces launched from the template data get
+func launchTemplateGroups(data *ec2.ResponseLaunchTemplateData) []string {
+	if data == nil {
+		return nil
+	}
+	groups := append(aws.StringValueSlice(data.SecurityGroupIds), aws.StringValueSlice(data.SecurityGroups)...)
+	for _, ni := range data.NetworkInterfaces {
+		groups = append(groups, aws.StringValueSlice(ni.Groups)...)
+	}
+	return groups
+}
+
+// groupReferences returns the IDs of the groups referenced by the
+// ingress and egress rules of the group
+func groupReferences(sg *ec2.SecurityGroup) []string {
+	var refs []string
+	for _, perms := range [][]*ec2.IpPermission{sg.IpPermissions, sg.IpPermissionsEgress} {
+		for _, p := range perms {
+			for _, pair := range p.UserIdGroupPairs {
+				refs = append(refs, aws.StringValue(pair.GroupId))
+			}
+		}
+	}
+	return refs
+}
+
+// Type returns the Security Group type
+func (sg SecurityGroup) Type() string { return "Security Group" }
+
+// ID returns the SecurityGroup ID
+func (sg SecurityGroup) ID() string { return *sg.GroupId }
+
+// Name returns the SecurityGroup ID
+func (sg SecurityGroup) Name() string { return *sg.GroupId }
+
+// Reason explains the SecurityGroup is unused
+func (sg SecurityGroup) Reason() string { return "not referenced" }
+
+// Since returns when the SecurityGroup was created according to CloudTrail
+func (sg SecurityGroup) Since(s *session.Session) (time.Time, error) {
+	return lastEvent(s, *sg.GroupId, time.Time{}, "CreateSecurityGroup")
+}
+
+// TagMap returns the tags of the SecurityGroup
+func (sg SecurityGroup) TagMap() map[string]string { return ec2TagMap(sg.Tags) }
+
+// References returns the groups the rules of the SecurityGroup reference
+func (sg SecurityGroup) References() map[string][]string {
+	var groups []string
+	for _, p := range append(filterGroupPermissions(sg.IpPermissions), filterGroupPermissions(sg.IpPermissionsEgress)...) {
+		for _, pair := range p.UserIdGroupPairs {
+			if id := aws.StringValue(pair.GroupId); id != *sg.GroupId {
+				groups = append(groups, id)
+			}
+		}
+	}
+	return map[string][]string{"security-group": groups}
+}
+
+// SweetenSteps describes the revocation of the group references
+func (sg SecurityGroup) SweetenSteps() []string {
+	if len(filterGroupPermissions(sg.IpPermissions))+len(filterGroupPermissions(sg.IpPermissionsEgress)) == 0 {
+		return nil
+	}
+	return []string{"revoke rules of " + *sg.GroupId + " referencing other groups"}
+}
+
+// Sweeten revokes the group references, see RevokeGroupReferences
+func (sg SecurityGroup) Sweeten(ctx aws.Context, s *session.Session, out io.Writer) (string, error) {
+	return "", sg.RevokeGroupReferences(s)
+}
+
+// RevokeGroupReferences removes the rules of the SecurityGroup that
+// reference other groups. Unused groups referencing each other can't
+// be deleted otherwise.
+func (sg SecurityGroup) RevokeGroupReferences(s *session.Session) error {
+	ingress := filterGroupPermissions(sg.IpPermissions)
+	egress := filterGroupPermissions(sg.IpPermissionsEgress)
+	ec2C := newEC2(s)
+	if len(ingress) > 0 {
+		if _, err := ec2C.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       sg.GroupId,
+			IpPermissions: ingress,
+		}); err != nil {
+			return fmt.Errorf("Couldn't revoke ingress rules of security group [%s]: %s",
+				*sg.GroupId, err)
+		}
+	}
+	if len(egress) > 0 {
+		if _, err := ec2C.RevokeSecurityGroupEgress(&ec2.RevokeSecurityGroupEgressInput{
+			GroupId:       sg.GroupId,
+			IpPermissions: egress,
+		}); err != nil {
+			return fmt.Errorf("Couldn't revoke egress rules of security group [%s]: %s",
+				*sg.GroupId, err)
+		}
+	}
+	return nil
+}
+
+// filterGroupPermissions keeps only the group pairs of the permissions
+func filterGroupPermissions(perms []*ec2.IpPermission) []*ec2.IpPermission {
+	var list []*ec2.IpPermission
+	for _, p := range perms {
+		if len(p.UserIdGroupPairs) == 0 {
+			continue
+		}
+		list = append(list, &ec2.IpPermission{
+			FromPort:         p.FromPort,
+			IpProtocol:       p.IpProtocol,
+			ToPort:           p.ToPort,
+			UserIdGroupPairs: p.UserIdGroupPairs,
+		})
+	}
+	return list
+}
+
+// Delete the SecurityGroup
+func (sg SecurityGroup) Delete(s *session.Session) error {
+	ec2C := newEC2(s)
+	if _, err := ec2C.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+		GroupId: sg.GroupId,
+	}); err != nil {
+		return fmt.Errorf("Couldn't delete security group [%s]: %s", *sg.GroupId, err)
+	}
+	return nil
+}
+
+func init() {
+	RegisterKind(Kind{
+		Name:        "security-group",
+		Aliases:     []string{"sg"},
+		Description: "security groups not referenced, their rules referencing other groups revoked first",
+		List: func(s *session.Session) ([]Deletable, error) {
+			res, err := ListUnusedSecurityGroups(s)
+			return deletables(res), err
+		},
+		Lookups: map[string]LookupFunc{SecurityGroup{}.Type(): lookupSecurityGroup},
+		// Unused groups can reference each other, those rules have to go
+		// before any of them can be deleted.
+		Batch: true,
+	})
+}
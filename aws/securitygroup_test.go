@@ -0,0 +1,38 @@
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestUnusedGroups(t *testing.T) {
+	group := func(id, name string, refs ...string) *ec2.SecurityGroup {
+		sg := &ec2.SecurityGroup{GroupId: aws.String(id), GroupName: aws.String(name)}
+		for _, ref := range refs {
+			sg.IpPermissions = append(sg.IpPermissions, &ec2.IpPermission{
+				UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String(ref)}},
+			})
+		}
+		return sg
+	}
+	groups := []*ec2.SecurityGroup{
+		group("sg-default", "default", "sg-db"),
+		group("sg-db", "db", "sg-backup"),
+		group("sg-backup", "backup"),
+		group("sg-web", "web", "sg-cache"),
+		group("sg-cache", "cache"),
+		group("sg-old", "old", "sg-older"),
+		group("sg-older", "older"),
+	}
+	var got []string
+	for _, sg := range unusedGroups(groups, map[string]bool{"web": true}) {
+		got = append(got, sg.ID())
+	}
+	want := []string{"sg-old", "sg-older"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unused %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,48 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// AccountID returns the ID of the account the session is authenticated against
+func AccountID(s *session.Session) (string, error) {
+	stsC := sts.New(s)
+	res, err := stsC.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("Couldn't get caller identity: %s", err)
+	}
+	return *res.Account, nil
+}
+
+// RoleOptions are the optional parameters to assume a role with
+type RoleOptions struct {
+	ExternalID  string
+	SessionName string
+	// MFASerial is the serial number of the MFA device to prompt a
+	// token for on the standard input
+	MFASerial string
+}
+
+// AssumeRole returns a copy of the session authenticated with the
+// credentials of the role. The role is assumed lazily on the first call
+// and credentials are refreshed when they expire.
+func AssumeRole(s *session.Session, roleARN string, opts RoleOptions) *session.Session {
+	creds := stscreds.NewCredentials(s, roleARN, func(p *stscreds.AssumeRoleProvider) {
+		if opts.ExternalID != "" {
+			p.ExternalID = aws.String(opts.ExternalID)
+		}
+		if opts.SessionName != "" {
+			p.RoleSessionName = opts.SessionName
+		}
+		if opts.MFASerial != "" {
+			p.SerialNumber = aws.String(opts.MFASerial)
+			p.TokenProvider = stscreds.StdinTokenProvider
+		}
+	})
+	return s.Copy(&aws.Config{Credentials: creds})
+}
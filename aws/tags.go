@@ -0,0 +1,115 @@
+package aws
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+)
+
+// Tags are handled as maps of key to value, EC2 and ELB tags being
+// converted from and to them.
+
+// awsTagKeys matches the keys prefixed with aws:, reserved to AWS
+const awsTagKeys = "aws:*"
+
+// globMatch tells if s matches the pattern, which can contain * wildcards
+func globMatch(pattern, s string) bool {
+	re := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$"
+	matched, _ := regexp.MatchString(re, s)
+	return matched
+}
+
+// mergeTags merges the maps of tags, the later ones winning on conflicts
+func mergeTags(maps ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, m := range maps {
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// omitTags returns the tags whose key doesn't match the glob pattern
+func omitTags(tags map[string]string, pattern string) map[string]string {
+	kept := make(map[string]string)
+	for k, v := range tags {
+		if !globMatch(pattern, k) {
+			kept[k] = v
+		}
+	}
+	return kept
+}
+
+// sortedTagKeys returns the keys of the tags in order
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FormatTags returns the tags as key=value, sorted by key
+func FormatTags(tags map[string]string) string {
+	list := make([]string, 0, len(tags))
+	for _, k := range sortedTagKeys(tags) {
+		list = append(list, k+"="+tags[k])
+	}
+	return strings.Join(list, ", ")
+}
+
+// ec2TagValue returns the value of the tag with the given key or an
+// empty string if there is none
+func ec2TagValue(tags []*ec2.Tag, key string) string {
+	for _, t := range tags {
+		if aws.StringValue(t.Key) == key {
+			return aws.StringValue(t.Value)
+		}
+	}
+	return ""
+}
+
+func ec2TagMap(tags []*ec2.Tag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, t := range tags {
+		m[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return m
+}
+
+// ec2Tags converts a map of tags to EC2 tags, sorted by key
+func ec2Tags(tags map[string]string) []*ec2.Tag {
+	list := make([]*ec2.Tag, 0, len(tags))
+	for _, k := range sortedTagKeys(tags) {
+		list = append(list, &ec2.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return list
+}
+
+// ec2UserTags drops the tags reserved to AWS, which can't be copied
+func ec2UserTags(tags []*ec2.Tag) []*ec2.Tag {
+	return ec2Tags(omitTags(ec2TagMap(tags), awsTagKeys))
+}
+
+func elbTagMap(tags []*elb.Tag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, t := range tags {
+		m[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return m
+}
+
+// elbTags converts a map of tags to ELB tags, sorted by key
+func elbTags(tags map[string]string) []*elb.Tag {
+	list := make([]*elb.Tag, 0, len(tags))
+	for _, k := range sortedTagKeys(tags) {
+		list = append(list, &elb.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return list
+}
@@ -0,0 +1,145 @@
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestGlobMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"env", "env", true},
+		{"env", "environment", false},
+		{"env*", "environment", true},
+		{"*", "", true},
+		{"aws:*", "aws:cloudformation:stack-name", true},
+		{"aws:*", "Name", false},
+		{"a*c", "abbc", true},
+		{"a*c", "abcd", false},
+		{"v1.0", "v100", false},
+		{"(x)", "(x)", true},
+	} {
+		if got := globMatch(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("globMatch(%q, %q) = %t, want %t", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		maps []map[string]string
+		want map[string]string
+	}{
+		{"none", nil, map[string]string{}},
+		{"nil map", []map[string]string{nil, {"a": "1"}}, map[string]string{"a": "1"}},
+		{
+			"later wins",
+			[]map[string]string{{"a": "1", "b": "2"}, {"b": "3", "c": "4"}},
+			map[string]string{"a": "1", "b": "3", "c": "4"},
+		},
+	} {
+		if got := mergeTags(tc.maps...); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: mergeTags = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestOmitTags(t *testing.T) {
+	tags := map[string]string{"Name": "web", "aws:autoscaling:groupName": "asg", "env": "prod"}
+	for _, tc := range []struct {
+		pattern string
+		want    map[string]string
+	}{
+		{awsTagKeys, map[string]string{"Name": "web", "env": "prod"}},
+		{"env", map[string]string{"Name": "web", "aws:autoscaling:groupName": "asg"}},
+		{"*", map[string]string{}},
+		{"none", tags},
+	} {
+		if got := omitTags(tags, tc.pattern); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("omitTags(%q) = %v, want %v", tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestFormatTags(t *testing.T) {
+	for _, tc := range []struct {
+		tags map[string]string
+		want string
+	}{
+		{nil, ""},
+		{map[string]string{"Name": "web"}, "Name=web"},
+		{map[string]string{"env": "prod", "Name": "web", "empty": ""}, "Name=web, empty=, env=prod"},
+	} {
+		if got := FormatTags(tc.tags); got != tc.want {
+			t.Errorf("FormatTags(%v) = %q, want %q", tc.tags, got, tc.want)
+		}
+	}
+}
+
+// ec2TagList builds EC2 tags from key, value pairs
+func ec2TagList(kv ...string) []*ec2.Tag {
+	list := []*ec2.Tag{}
+	for i := 0; i < len(kv); i += 2 {
+		list = append(list, &ec2.Tag{Key: aws.String(kv[i]), Value: aws.String(kv[i+1])})
+	}
+	return list
+}
+
+func TestEC2TagValue(t *testing.T) {
+	tags := ec2TagList("Name", "web", "env", "")
+	for _, tc := range []struct {
+		key, want string
+	}{
+		{"Name", "web"},
+		{"env", ""},
+		{"missing", ""},
+		{"name", ""},
+	} {
+		if got := ec2TagValue(tags, tc.key); got != tc.want {
+			t.Errorf("ec2TagValue(%q) = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+	if got := ec2TagValue(nil, "Name"); got != "" {
+		t.Errorf("ec2TagValue(nil) = %q, want empty", got)
+	}
+}
+
+func TestEC2Tags(t *testing.T) {
+	for _, tc := range []struct {
+		tags map[string]string
+		want []*ec2.Tag
+	}{
+		{nil, ec2TagList()},
+		{map[string]string{"b": "2", "a": "1", "C": "3"}, ec2TagList("C", "3", "a", "1", "b", "2")},
+	} {
+		if got := ec2Tags(tc.tags); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ec2Tags(%v) = %v, want %v", tc.tags, got, tc.want)
+		}
+	}
+}
+
+func TestEC2UserTags(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		tags []*ec2.Tag
+		want []*ec2.Tag
+	}{
+		{"none", nil, ec2TagList()},
+		{
+			"reserved dropped and sorted",
+			ec2TagList("env", "prod", "aws:cloudformation:stack-id", "x", "Name", "web"),
+			ec2TagList("Name", "web", "env", "prod"),
+		},
+		{"only reserved", ec2TagList("aws:createdBy", "x"), ec2TagList()},
+	} {
+		if got := ec2UserTags(tc.tags); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: ec2UserTags = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
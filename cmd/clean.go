@@ -1,11 +1,13 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"time"
 
-	awssdk "github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/ec2"
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
 
@@ -14,165 +16,259 @@ import (
 
 // cleanCmd represents the clean command
 var cleanCmd = &cobra.Command{
-	Use:   "clean [type]",
+	Use:   "clean [type|all]",
 	Short: "Clean your AWS account in various places",
 	Long: `Clean your AWS account in various places including:
 	
-	- Soft kill an EC2 instance with a snapshot first
+	- Soft kill an EC2 instance with an AMI first
 	- Remove deprecated ELB without target instances
+	- Remove ALB and NLB without registered or healthy targets and unattached
+	  Groups, exporting them to JSON first
 	- Remove available volumes and snapshot them
 	- Release unattached Elastic IPs and Network Interfaces
 	- Remove unused Security Groups
-	- Remove unused Launch Configurations`,
-	Args: cobra.MinimumNArgs(1),
+	- Remove unused Launch Configurations
+
+	See --list-types for every type along with its aliases. clean all cleans
+	every type, the types referencing others first. EC2 instances are only
+	part of it when selected, the next types being cleaned once the
+	instances are terminated.
+	Resources tagged awsugar:protect=true are never cleaned, see --protect-tag.
+	On a terminal, the deletions have to be confirmed by typing yes, unless
+	--yes is given. --interactive asks for each resource instead.`,
+	Args: cobra.MaximumNArgs(1),
 	Run:  cleanFunc,
 }
 
 var cleanFlags struct {
-	SweetClean bool
-	EC2List    []string
+	SweetClean      bool
+	EC2List         []string
+	EC2Tags         []string
+	EC2State        string
+	EC2StoppedFor   durationValue
+	EC2All          bool
+	LaunchTemplates bool
+	PlanOut         string
+	OlderThan       ageValue
+	ListTypes       bool
 }
 
 func cleanFunc(cmd *cobra.Command, args []string) {
-	resource := args[0]
-	switch resource {
-	case "ec2":
-		cleanEC2()
-	case "elb":
-		cleanELB()
-	case "ebs":
-		cleanEBS()
-	case "network-interface":
-		cleanNetworkInterfaces()
-	default:
-		fmt.Println("Resource type not supported")
+	if cleanFlags.ListTypes {
+		for _, k := range aws.Kinds() {
+			addRecord(newKindRecord(k))
+		}
+		if err := printRecords(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) < 1 {
+		cmd.Usage()
+		return
+	}
+	var kinds []aws.Kind
+	if args[0] == "all" {
+		for _, k := range aws.Kinds() {
+			// Instances are never terminated unless selected.
+			if k.Name == "ec2" && !ec2Selected() {
+				fmt.Fprintln(os.Stderr, "EC2 instances left out, select them with --ids, --tag, --state, --stopped-for or --all")
+				continue
+			}
+			kinds = append(kinds, k)
+		}
+	} else {
+		kind, ok := aws.LookupKind(args[0])
+		if !ok {
+			log.Fatalf("Resource type [%s] not supported, see clean --list-types", args[0])
+		}
+		kinds = []aws.Kind{kind}
+	}
+	for _, k := range kinds {
+		if k.Name == "ec2" {
+			sel, err := ec2Selector()
+			if err != nil {
+				log.Fatal(err)
+			}
+			aws.Listing.Instances = sel
+		}
+	}
+	aws.Listing.LaunchTemplates = cleanFlags.LaunchTemplates
+	if err := startRun(); err != nil {
+		log.Fatal(err)
+	}
+	err := forEachTarget(func(t *target) error { return cleanKinds(t, kinds) })
+	if cleanFlags.PlanOut != "" {
+		if err := writePlan(cleanFlags.PlanOut); err != nil {
+			log.Fatal(err)
+		}
+	}
+	printJournal()
+	if err := printRecords(); err != nil {
+		log.Fatal(err)
+	}
+	if err != nil {
+		log.Fatal(err)
 	}
 }
 
 func init() {
 	rootCmd.AddCommand(cleanCmd)
+	cleanCmd.ValidArgs = []string{"all"}
+	for _, k := range aws.Kinds() {
+		cleanCmd.ValidArgs = append(cleanCmd.ValidArgs, k.Name)
+		cleanCmd.ArgAliases = append(cleanCmd.ArgAliases, k.Aliases...)
+	}
 
 	cleanCmd.PersistentFlags().BoolVarP(&cleanFlags.SweetClean, "sweet-clean",
-		"s", true, "allow some preparation before cleaning (snapshot, etc.)")
+		"s", true, "allow some preparation before cleaning (AMI, snapshot, etc.)")
 
 	cleanCmd.Flags().StringSliceVar(&cleanFlags.EC2List, "ids", []string{},
 		"List of EC2 instance IDs to clean")
+	cleanCmd.Flags().StringArrayVar(&cleanFlags.EC2Tags, "tag", []string{},
+		"clean EC2 instances with the tag key=value, key=val* or key (can be repeated)")
+	cleanCmd.Flags().StringVar(&cleanFlags.EC2State, "state", "",
+		"clean EC2 instances in the given state (e.g. stopped)")
+	cleanCmd.Flags().Var(&cleanFlags.EC2StoppedFor, "stopped-for",
+		"clean EC2 instances stopped for longer than the duration (e.g. 30d)")
+	cleanCmd.Flags().BoolVar(&cleanFlags.EC2All, "all", false,
+		"clean every EC2 instance of the regions, requires to confirm the account ID")
+	cleanCmd.Flags().BoolVar(&cleanFlags.LaunchTemplates, "launch-templates", false,
+		"also clean launch template versions that are neither default nor latest")
+	cleanCmd.Flags().StringVar(&cleanFlags.PlanOut, "plan-out", "",
+		"write the resources to clean to a plan file for awsugar apply instead of cleaning them")
+	cleanCmd.Flags().Var(&cleanFlags.OlderThan, "older-than",
+		"only clean resources unused for longer than the duration (e.g. 30d), per type with type=duration")
+	cleanCmd.Flags().BoolVar(&cleanFlags.ListTypes, "list-types", false,
+		"list the types of resources clean handles")
+	addRunFlags(cleanCmd.Flags())
 }
 
-func cleanAbstractList(list []aws.Deletable) error {
-	var retErr *multierror.Error
-	for _, d := range list {
-		fmt.Printf("%s [%s] to be deleted...\n", d.Type(), d.Name())
-		if !rootFlags.DryRun {
-			if err := d.Delete(sess); err != nil {
-				retErr = multierror.Append(retErr, err)
-			} else {
-				fmt.Printf("%s [%s] deleted successfully!\n", d.Type(), d.Name())
-			}
+// cleanKinds cleans the kinds one after the other, the kinds referencing
+// others first, according to the references between the resources of the
+// kinds. Each kind is listed again right before being cleaned, as the
+// deletions of the previous ones free up its resources, once the deleted
+// resources of the previous kind are gone.
+func cleanKinds(t *target, kinds []aws.Kind) error {
+	if len(kinds) == 1 {
+		_, err := cleanKind(t, kinds[0])
+		return err
+	}
+	listed := make(map[string][]aws.Deletable, len(kinds))
+	for _, k := range kinds {
+		list, err := k.List(t.Session)
+		if err != nil {
+			return err
 		}
+		listed[k.Name] = list
 	}
-	return retErr.ErrorOrNil()
-}
-
-func sweetenList(list []aws.Sweetener) error {
 	var retErr *multierror.Error
-	for _, d := range list {
-		if !rootFlags.DryRun {
-			if err := d.Sweeten(sess); err != nil {
-				retErr = multierror.Append(retErr, err)
-			}
+	ordered := aws.OrderKinds(kinds, listed)
+	for i, k := range ordered {
+		fmt.Fprintf(os.Stderr, "-- %s --\n", k.Name)
+		deleted, err := cleanKind(t, k)
+		if err != nil {
+			retErr = multierror.Append(retErr, err)
+		}
+		if i == len(ordered)-1 {
+			continue
+		}
+		if err := waitDeleted(t, k, deleted); err != nil {
+			retErr = multierror.Append(retErr, err)
 		}
 	}
 	return retErr.ErrorOrNil()
 }
 
-func cleanEC2() {
-	idList := make([]*string, 0, len(cleanFlags.EC2List))
-	for i := range cleanFlags.EC2List {
-		idList = append(idList, &cleanFlags.EC2List[i])
-	}
-	res, err := aws.ListInstances(sess, idList)
-	if err != nil {
-		log.Fatal(err)
-	}
-	deletableList := make([]aws.Deletable, len(res))
-	for i, d := range res {
-		deletableList[i] = d
-	}
-	if cleanFlags.SweetClean {
-		var sweetList []aws.Sweetener
-		for i := range res {
-			for j := range res[i].BlockDeviceMappings {
-				ebsVolume := aws.EBSVolume{&ec2.Volume{}}
-				ebsVolume.VolumeId = res[i].BlockDeviceMappings[j].Ebs.VolumeId
-				ebsVolume.SetTags(res[i].Tags)
-				ebsVolume.Tags = append(ebsVolume.Tags, &ec2.Tag{
-					Key:   awssdk.String("mount_point"),
-					Value: res[i].BlockDeviceMappings[j].DeviceName,
-				})
-				sweetList = append(sweetList, ebsVolume)
-			}
-		}
-		// To prevent still deleting if error while sweetening.
-		// Need to do at the item level.
-		if err := sweetenList(sweetList); err != nil {
-			log.Fatal(err)
-		}
-	}
-	if err := cleanAbstractList(deletableList); err != nil {
-		log.Println(err)
+// waitDeleted waits for the resources of the kind deleted in the target
+// to be gone, when the kind has to
+func waitDeleted(t *target, k aws.Kind, deleted []aws.Deletable) error {
+	if k.WaitDeleted == nil || len(deleted) == 0 {
+		return nil
 	}
+	fmt.Fprintf(os.Stderr, "Waiting for the %d deleted %s resources to be gone...\n", len(deleted), k.Name)
+	ctx, cancel := waitContext()
+	defer cancel()
+	return k.WaitDeleted(ctx, t.Session, deleted)
 }
 
-func cleanELB() {
-	res, err := aws.ListInactiveLoadBalancers(sess)
+// cleanKind runs the Sweeten→Delete transaction of every resource of the
+// kind not excluded from the run, the resources referencing others of the
+// list first, and returns the deleted resources. With --plan-out, the
+// resources are added to the plan instead of being cleaned.
+func cleanKind(t *target, kind aws.Kind) ([]aws.Deletable, error) {
+	list, err := kind.List(t.Session)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	minAge = cleanFlags.OlderThan.For(kind.Name)
+	if list, err = excludeList(t, aws.OrderDeletables(list)); err != nil {
+		return nil, err
 	}
-	deletableList := make([]aws.Deletable, len(res))
-	for i, d := range res {
-		deletableList[i] = d
+	sweeten := kind.Batch || kind.Sweeten && cleanFlags.SweetClean
+	if cleanFlags.PlanOut != "" {
+		return nil, addToPlan(t, list, sweeten)
 	}
-	if err := cleanAbstractList(deletableList); err != nil {
-		log.Println(err)
+	txs := newTransactions(t, list, sweeten)
+	err = runTransactions(txs, kind.Batch)
+	var deleted []aws.Deletable
+	for _, tx := range txs {
+		if tx.state == stateDeleted {
+			deleted = append(deleted, tx.deletable)
+		}
 	}
+	return deleted, err
 }
 
-func cleanNetworkInterfaces() {
-	res, err := aws.ListUnattachedNetworkInterfaces(sess)
-	if err != nil {
-		log.Fatal(err)
+// ec2Selected tells if EC2 instances were selected through the flags
+func ec2Selected() bool {
+	return cleanFlags.EC2All || len(cleanFlags.EC2List) > 0 || len(cleanFlags.EC2Tags) > 0 ||
+		cleanFlags.EC2State != "" || cleanFlags.EC2StoppedFor > 0
+}
+
+// ec2Selector builds the instance selector from the flags. Terminating
+// every instance of a region is never implied, at least one selector is
+// mandatory and --all has to be confirmed by typing the account ID.
+func ec2Selector() (aws.InstanceSelector, error) {
+	sel := aws.InstanceSelector{
+		IDs:        cleanFlags.EC2List,
+		State:      cleanFlags.EC2State,
+		StoppedFor: time.Duration(cleanFlags.EC2StoppedFor),
 	}
-	deletableList := make([]aws.Deletable, len(res))
-	for i, d := range res {
-		deletableList[i] = d
+	for _, t := range cleanFlags.EC2Tags {
+		f, err := aws.ParseTagFilter(t)
+		if err != nil {
+			return sel, err
+		}
+		sel.Tags = append(sel.Tags, f)
 	}
-	if err := cleanAbstractList(deletableList); err != nil {
-		log.Println(err)
+	narrowed := len(sel.IDs) > 0 || len(sel.Tags) > 0 || sel.State != "" || sel.StoppedFor > 0
+	if !cleanFlags.EC2All {
+		if !narrowed {
+			return sel, errors.New("One of --ids, --tag, --state, --stopped-for or --all is required to clean EC2 instances")
+		}
+		return sel, nil
 	}
-}
-
-func cleanEBS() {
-	res, err := aws.ListAvailableEBS(sess)
-	if err != nil {
-		log.Fatal(err)
+	if narrowed {
+		return sel, errors.New("--all can't be combined with other EC2 selectors")
 	}
-	deletableList := make([]aws.Deletable, len(res))
-	for i, d := range res {
-		deletableList[i] = d
+	if rootFlags.DryRun {
+		return sel, nil
 	}
-	if cleanFlags.SweetClean {
-		sweetList := make([]aws.Sweetener, len(res))
-		for i, d := range res {
-			sweetList[i] = d
+	list, err := targetAccounts()
+	if err != nil {
+		return sel, err
+	}
+	for _, a := range list {
+		regions, err := a.targetRegions()
+		if err != nil {
+			return sel, err
 		}
-		// To prevent still deleting if error while sweetening.
-		// Need to do at the item level.
-		if err := sweetenList(sweetList); err != nil {
-			log.Fatal(err)
+		fmt.Fprintf(os.Stderr, "Every EC2 instance of account %s in %s will be terminated.\n",
+			a.ID, strings.Join(regions, ", "))
+		if ask("Type the account ID to confirm: ") != a.ID {
+			return sel, errors.New("Account ID doesn't match, aborting")
 		}
 	}
-	if err := cleanAbstractList(deletableList); err != nil {
-		log.Println(err)
-	}
+	return sel, nil
 }
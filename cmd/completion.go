@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh]",
+	Short: "Generate the shell completion script",
+	Long: `Print the completion script of the shell, completing the commands, the
+	flags and the types of resources of clean:
+
+	  source <(awsugar completion bash)`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"bash", "zsh"},
+	Run:       completionFunc,
+}
+
+func completionFunc(cmd *cobra.Command, args []string) {
+	var err error
+	switch args[0] {
+	case "bash":
+		err = rootCmd.GenBashCompletion(os.Stdout)
+	case "zsh":
+		err = rootCmd.GenZshCompletion(os.Stdout)
+	default:
+		log.Fatalf("Shell [%s] not supported, expected bash or zsh", args[0])
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the awsugar configuration",
+	Long: `Every flag can be given a default in a config file, using the name of
+	the flag as key. Settings at the top level apply to every command
+	having the flag, settings under the name of a command only to it:
+
+	  region: [us-east-1, eu-west-1]
+	  protect-tag: keep=true
+	  clean:
+	    sweeten-timeout: 4h
+	    older-than: {ebs: 30d, eip: 7d}
+
+	Flags override the AWSUGAR_* environment variables (AWSUGAR_REGION for
+	--region), which override ./.awsugar.yaml, which overrides
+	~/.awsugar.yaml.`,
+}
+
+// configViewCmd represents the config view command
+var configViewCmd = &cobra.Command{
+	Use:   "view",
+	Short: "Show the effective configuration",
+	Long: `Show the effective value of every setting, merged from the flags, the
+	environment and the config files, along with where it comes from.`,
+	Args: cobra.NoArgs,
+	Run:  configViewFunc,
+}
+
+// configFileName is the name of the config files in the home and the
+// project directories
+const configFileName = ".awsugar.yaml"
+
+// configLayer is a set of settings, by flag name, from a single source
+type configLayer struct {
+	Source   string
+	Global   map[string][]string
+	Commands map[string]map[string][]string
+	// env reads the settings from the environment instead
+	env bool
+}
+
+// lookup returns the values of the setting for the command
+func (l configLayer) lookup(c *cobra.Command, name string) ([]string, bool) {
+	if l.env {
+		v, ok := os.LookupEnv(envName(name))
+		return []string{v}, ok
+	}
+	if values, ok := l.Commands[c.Name()][name]; ok {
+		return values, true
+	}
+	values, ok := l.Global[name]
+	return values, ok
+}
+
+// envName returns the environment variable of a setting
+func envName(name string) string {
+	return "AWSUGAR_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// configLayers returns the layers of settings from the weakest to the
+// strongest: home file, project file and environment
+func configLayers() ([]configLayer, error) {
+	var layers []configLayer
+	paths := []string{configFileName}
+	if home := os.Getenv("HOME"); home != "" {
+		paths = []string{filepath.Join(home, configFileName), configFileName}
+	}
+	for _, path := range paths {
+		l, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if l != nil {
+			layers = append(layers, *l)
+		}
+	}
+	return append(layers, configLayer{Source: "env", env: true}), nil
+}
+
+// readConfigFile reads a config file, nil if it doesn't exist
+func readConfigFile(path string) (*configLayer, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read config file: %s", err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("Couldn't parse config file [%s]: %s", path, err)
+	}
+	l := &configLayer{
+		Source:   path,
+		Global:   make(map[string][]string),
+		Commands: make(map[string]map[string][]string),
+	}
+	for key, v := range raw {
+		c := findCommand(key)
+		section, ok := v.(map[interface{}]interface{})
+		if c == nil || !ok {
+			if !isFlag(rootCmd, key) {
+				return nil, fmt.Errorf("Unknown setting [%s] in config file [%s]", key, path)
+			}
+			l.Global[key] = configValues(v)
+			continue
+		}
+		l.Commands[key] = make(map[string][]string)
+		for name, v := range section {
+			name := fmt.Sprint(name)
+			if !isFlag(c, name) {
+				return nil, fmt.Errorf("Unknown setting [%s.%s] in config file [%s]", key, name, path)
+			}
+			l.Commands[key][name] = configValues(v)
+		}
+	}
+	return l, nil
+}
+
+// configValues turns a setting into flag values: a list gives a value per
+// item and a map a key=value per entry
+func configValues(v interface{}) []string {
+	switch v := v.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	case map[interface{}]interface{}:
+		values := make([]string, 0, len(v))
+		for k, item := range v {
+			values = append(values, fmt.Sprintf("%v=%v", k, item))
+		}
+		sort.Strings(values)
+		return values
+	}
+	return []string{fmt.Sprint(v)}
+}
+
+// findCommand returns the subcommand of the given name, nil if none
+func findCommand(name string) *cobra.Command {
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// isFlag tells if the flag exists in the command or one of its subcommands
+func isFlag(c *cobra.Command, name string) bool {
+	if c.Flags().Lookup(name) != nil || c.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, sub := range c.Commands() {
+		if isFlag(sub, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyConfig sets the flags of the command that weren't given on the
+// command line from the strongest layer having them. It returns where
+// the value of each flag comes from.
+func applyConfig(c *cobra.Command, flags *pflag.FlagSet, layers []configLayer) (map[string]string, error) {
+	sources := make(map[string]string)
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			sources[f.Name] = "flag"
+			return
+		}
+		sources[f.Name] = "default"
+		for i := len(layers) - 1; i >= 0; i-- {
+			values, ok := layers[i].lookup(c, f.Name)
+			if !ok {
+				continue
+			}
+			for _, v := range values {
+				if setErr := f.Value.Set(v); setErr != nil && err == nil {
+					err = fmt.Errorf("Invalid setting [%s] from %s: %s", f.Name, layers[i].Source, setErr)
+				}
+			}
+			sources[f.Name] = layers[i].Source
+			return
+		}
+	})
+	return sources, err
+}
+
+// configSources tells where the value of each flag of the command being
+// run comes from
+var configSources map[string]string
+
+// initConfig applies the config to the flags of the command being run
+func initConfig() {
+	c, _, _ := rootCmd.Find(os.Args[1:])
+	layers, err := configLayers()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if configSources, err = applyConfig(c, c.Flags(), layers); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func configViewFunc(cmd *cobra.Command, args []string) {
+	layers, err := configLayers()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, l := range layers[:len(layers)-1] {
+		fmt.Printf("# from %s\n", l.Source)
+	}
+	// The flags of config view itself are already set by initConfig.
+	printSettings(rootCmd.PersistentFlags(), configSources, "")
+	for _, c := range rootCmd.Commands() {
+		flags := c.LocalFlags()
+		if !hasSettings(flags) {
+			continue
+		}
+		sources, err := applyConfig(c, flags, layers)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s:\n", c.Name())
+		printSettings(flags, sources, "  ")
+	}
+}
+
+// hasSettings tells if the flags have others than help
+func hasSettings(flags *pflag.FlagSet) bool {
+	has := false
+	flags.VisitAll(func(f *pflag.Flag) {
+		has = has || f.Name != "help"
+	})
+	return has
+}
+
+// printSettings prints the flags as settings along with their source
+func printSettings(flags *pflag.FlagSet, sources map[string]string, indent string) {
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" {
+			return
+		}
+		value := f.Value.String()
+		if value == "" {
+			value = `""`
+		}
+		fmt.Printf("%s%s: %s # %s\n", indent, f.Name, value, sources[f.Name])
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configViewCmd)
+}
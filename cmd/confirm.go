@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Dal-Papa/awsugar/aws"
+)
+
+// confirmFlags are the flags controlling the confirmation of deletions
+var confirmFlags struct {
+	Interactive bool
+	Yes         bool
+}
+
+// stdin reads the answers to every prompt
+var stdin = bufio.NewReader(os.Stdin)
+
+var (
+	// confirmAll is set once all was answered, nothing more is asked
+	confirmAll bool
+	// confirmQuit is set once quit was answered, nothing more is deleted
+	confirmQuit bool
+)
+
+// isTerminal tells if stdin is a terminal someone can answer from
+func isTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// ask prints the question to stderr and returns the answer in lower case,
+// quit once stdin is closed
+func ask(question string) string {
+	fmt.Fprint(os.Stderr, question)
+	answer, err := stdin.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(os.Stderr)
+		return "quit"
+	}
+	return strings.ToLower(strings.TrimSpace(answer))
+}
+
+// confirm asks for the transactions to run, the declined ones are
+// skipped. With --interactive each resource is asked for, otherwise the
+// whole list is confirmed at once. Nothing is asked with --yes or when
+// stdin isn't a terminal, as in CI.
+func confirm(txs []*transaction) {
+	if len(txs) == 0 || rootFlags.DryRun || confirmAll {
+		return
+	}
+	if confirmQuit {
+		decline(txs, "quit")
+		return
+	}
+	if confirmFlags.Interactive {
+		confirmEach(txs)
+		return
+	}
+	if confirmFlags.Yes || !isTerminal() {
+		return
+	}
+	for _, tx := range txs {
+		d := tx.deletable
+		fmt.Fprintf(os.Stderr, "%s [%s]: %s\n", d.Type(), d.Name(), d.Reason())
+	}
+	fmt.Fprintf(os.Stderr, "%d resources will be deleted, ", len(txs))
+	if ask("type 'yes' to continue: ") != "yes" {
+		decline(txs, "not confirmed")
+	}
+}
+
+// confirmEach describes every resource and asks whether to delete it
+func confirmEach(txs []*transaction) {
+	for i, tx := range txs {
+		describe(tx)
+		for answered := false; !answered; {
+			answered = true
+			switch ask("Delete? [y]es, [n]o, [a]ll, [q]uit: ") {
+			case "y", "yes":
+			case "n", "no":
+				decline(txs[i:i+1], "declined")
+			case "a", "all":
+				confirmAll = true
+				return
+			case "q", "quit":
+				confirmQuit = true
+				decline(txs[i:], "quit")
+				return
+			default:
+				answered = false
+			}
+		}
+	}
+}
+
+// decline skips the transactions for the reason
+func decline(txs []*transaction, why string) {
+	for _, tx := range txs {
+		tx.state = stateSkipped
+		tx.reason = why
+	}
+}
+
+// describe prints the details of the resource of the transaction: tags,
+// age, size and how it will be sweetened
+func describe(tx *transaction) {
+	d := tx.deletable
+	fmt.Fprintf(os.Stderr, "\n%s [%s] %s\n", d.Type(), d.ID(), d.Name())
+	fmt.Fprintf(os.Stderr, "  reason:  %s\n", d.Reason())
+	if t, ok := d.(aws.Tagged); ok {
+		fmt.Fprintf(os.Stderr, "  tags:    %s\n", aws.FormatTags(t.TagMap()))
+	}
+	if a, ok := d.(aws.Aged); ok {
+		since, err := a.Since(tx.target.Session)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  age:     unknown, %s\n", err)
+		} else {
+			age := time.Since(since).Truncate(time.Hour)
+			fmt.Fprintf(os.Stderr, "  age:     %s, since %s\n", age, since.Format(time.RFC3339))
+		}
+	}
+	switch r := d.(type) {
+	case aws.EBSVolume:
+		fmt.Fprintf(os.Stderr, "  size:    %d GiB\n", *r.Size)
+	case aws.EC2Instance:
+		fmt.Fprintf(os.Stderr, "  size:    %s\n", *r.InstanceType)
+	}
+	if sw, ok := d.(aws.Sweetener); ok && tx.sweeten {
+		for _, step := range sw.SweetenSteps() {
+			fmt.Fprintf(os.Stderr, "  sweeten: %s\n", step)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dal-Papa/awsugar/aws"
+)
+
+// durationValue is a time.Duration flag that also accepts a number of
+// days such as 30d
+type durationValue time.Duration
+
+func (d *durationValue) Set(s string) error {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err == nil {
+			*d = durationValue(time.Duration(days) * 24 * time.Hour)
+			return nil
+		}
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = durationValue(v)
+	return nil
+}
+
+func (d *durationValue) Type() string { return "duration" }
+
+func (d *durationValue) String() string { return time.Duration(*d).String() }
+
+// ageValue is a flag holding a duration per resource type, given as
+// type=duration entries where type is the name or an alias of a kind. An
+// entry without type applies to every type.
+type ageValue map[string]time.Duration
+
+func (a *ageValue) Set(s string) error {
+	if *a == nil {
+		*a = make(ageValue)
+	}
+	for _, entry := range strings.Split(s, ",") {
+		typ, value := "", entry
+		if parts := strings.SplitN(entry, "=", 2); len(parts) == 2 {
+			kind, ok := aws.LookupKind(parts[0])
+			if !ok {
+				return fmt.Errorf("Unknown resource type [%s]", parts[0])
+			}
+			typ, value = kind.Name, parts[1]
+		}
+		var d durationValue
+		if err := d.Set(value); err != nil {
+			return err
+		}
+		(*a)[typ] = time.Duration(d)
+	}
+	return nil
+}
+
+func (a *ageValue) Type() string { return "[type=]duration" }
+
+func (a *ageValue) String() string {
+	entries := make([]string, 0, len(*a))
+	for typ, d := range *a {
+		if typ == "" {
+			entries = append(entries, d.String())
+		} else {
+			entries = append(entries, typ+"="+d.String())
+		}
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// For returns the duration of the resource type
+func (a ageValue) For(typ string) time.Duration {
+	if d, ok := a[typ]; ok {
+		return d
+	}
+	return a[""]
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/Dal-Papa/awsugar/aws"
+)
+
+// outputFormats are the formats of --output
+var outputFormats = []string{"table", "json", "yaml", "csv"}
+
+// tabular is a record of the output that can be printed as a table row
+type tabular interface {
+	header() []string
+	row() []string
+}
+
+// resourceRecord is the outcome of an action on a resource
+type resourceRecord struct {
+	Type     string `json:"type" yaml:"type"`
+	ID       string `json:"id" yaml:"id"`
+	Name     string `json:"name" yaml:"name"`
+	Region   string `json:"region" yaml:"region"`
+	Account  string `json:"account" yaml:"account"`
+	Action   string `json:"action" yaml:"action"`
+	Status   string `json:"status" yaml:"status"`
+	Reason   string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+	Artifact string `json:"artifact,omitempty" yaml:"artifact,omitempty"`
+}
+
+func (r resourceRecord) header() []string {
+	return []string{"TYPE", "ID", "NAME", "REGION", "ACCOUNT", "ACTION", "STATUS", "REASON", "ERROR", "ARTIFACT"}
+}
+
+func (r resourceRecord) row() []string {
+	return []string{r.Type, r.ID, r.Name, r.Region, r.Account, r.Action, r.Status, r.Reason, r.Error, r.Artifact}
+}
+
+// ipRecord is a resource owning or pointing to a searched IP
+type ipRecord struct {
+	IP         string `json:"ip" yaml:"ip"`
+	Type       string `json:"type" yaml:"type"`
+	ID         string `json:"id" yaml:"id"`
+	Name       string `json:"name" yaml:"name"`
+	HostedZone string `json:"hostedZone,omitempty" yaml:"hostedZone,omitempty"`
+	Region     string `json:"region" yaml:"region"`
+	Account    string `json:"account" yaml:"account"`
+}
+
+func (r ipRecord) header() []string {
+	return []string{"IP", "TYPE", "ID", "NAME", "HOSTED ZONE", "REGION", "ACCOUNT"}
+}
+
+func (r ipRecord) row() []string {
+	return []string{r.IP, r.Type, r.ID, r.Name, r.HostedZone, r.Region, r.Account}
+}
+
+// tagRecord is a resource matching the searched tags
+type tagRecord struct {
+	Type    string            `json:"type" yaml:"type"`
+	ID      string            `json:"id" yaml:"id"`
+	Name    string            `json:"name" yaml:"name"`
+	Region  string            `json:"region" yaml:"region"`
+	Account string            `json:"account" yaml:"account"`
+	Tags    map[string]string `json:"tags" yaml:"tags"`
+}
+
+func (r tagRecord) header() []string {
+	return []string{"TYPE", "ID", "NAME", "REGION", "ACCOUNT", "TAGS"}
+}
+
+func (r tagRecord) row() []string {
+	return []string{r.Type, r.ID, r.Name, r.Region, r.Account, aws.FormatTags(r.Tags)}
+}
+
+// kindRecord is a type of resources clean handles
+type kindRecord struct {
+	Name        string   `json:"name" yaml:"name"`
+	Aliases     []string `json:"aliases" yaml:"aliases"`
+	Sweeten     string   `json:"sweeten" yaml:"sweeten"`
+	Description string   `json:"description" yaml:"description"`
+}
+
+func newKindRecord(k aws.Kind) kindRecord {
+	r := kindRecord{Name: k.Name, Aliases: k.Aliases, Sweeten: "no", Description: k.Description}
+	switch {
+	case k.Batch:
+		r.Sweeten = "always"
+	case k.Sweeten:
+		r.Sweeten = "yes"
+	}
+	return r
+}
+
+func (r kindRecord) header() []string {
+	return []string{"NAME", "ALIASES", "SWEETEN", "DESCRIPTION"}
+}
+
+func (r kindRecord) row() []string {
+	return []string{r.Name, strings.Join(r.Aliases, ","), r.Sweeten, r.Description}
+}
+
+// newRecord returns the record of an action on a resource of the target
+func newRecord(t *target, d aws.Deletable, action, status string) resourceRecord {
+	return resourceRecord{
+		Type:    d.Type(),
+		ID:      d.ID(),
+		Name:    d.Name(),
+		Region:  t.Region,
+		Account: t.Account,
+		Action:  action,
+		Status:  status,
+	}
+}
+
+// records are the records of the run, printed by printRecords
+var records = []tabular{}
+
+// recordsMu guards records, resources being processed concurrently
+var recordsMu sync.Mutex
+
+func addRecord(r tabular) {
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+	records = append(records, r)
+}
+
+// initOutput checks --output
+func initOutput() {
+	for _, f := range outputFormats {
+		if rootFlags.Output == f {
+			return
+		}
+	}
+	log.Fatalf("Unknown output format [%s], expected one of %s",
+		rootFlags.Output, strings.Join(outputFormats, ", "))
+}
+
+// printRecords prints the records of the run to stdout in the --output
+// format. Everything else goes to stderr, so stdout can be consumed by
+// other tools.
+func printRecords() error {
+	switch rootFlags.Output {
+	case "json":
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Couldn't encode output: %s", err)
+		}
+		fmt.Println(string(data))
+	case "yaml":
+		data, err := yaml.Marshal(records)
+		if err != nil {
+			return fmt.Errorf("Couldn't encode output: %s", err)
+		}
+		fmt.Print(string(data))
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		var header []string
+		for _, r := range records {
+			// A new header starts each kind of records.
+			if h := r.header(); strings.Join(h, ",") != strings.Join(header, ",") {
+				header = h
+				w.Write(header)
+			}
+			w.Write(r.row())
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		var header []string
+		for _, r := range records {
+			if h := r.header(); strings.Join(h, ",") != strings.Join(header, ",") {
+				if header != nil {
+					fmt.Fprintln(w)
+				}
+				header = h
+				fmt.Fprintln(w, strings.Join(header, "\t"))
+			}
+			fmt.Fprintln(w, strings.Join(r.row(), "\t"))
+		}
+		return w.Flush()
+	}
+	return nil
+}
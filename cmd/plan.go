@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/spf13/cobra"
+
+	"github.com/Dal-Papa/awsugar/aws"
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply [plan]",
+	Short: "Apply a plan written by clean --plan-out",
+	Long: `Clean exactly the resources listed in a plan written by clean --plan-out.
+
+	Every resource is looked up first and skipped if it doesn't exist
+	anymore, if it changed since the plan was written or if clean wouldn't
+	list it anymore, such as a security group used again.
+
+	EC2 instances are sweetened as they were planned, with an AMI or by
+	snapshotting their volumes, regardless of the --snapshot-volumes and
+	--no-reboot given to apply.
+
+	Resources are deleted kind by kind in the order clean would use, the
+	resources referencing others first.`,
+	Args: cobra.ExactArgs(1),
+	Run:  applyFunc,
+}
+
+// Plan is the list of resources a clean run would delete
+type Plan struct {
+	Created time.Time   `json:"created"`
+	Entries []PlanEntry `json:"entries"`
+}
+
+// PlanEntry is a resource to delete along with how to prepare it
+type PlanEntry struct {
+	Type        string   `json:"type"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Region      string   `json:"region"`
+	Account     string   `json:"account"`
+	Reason      string   `json:"reason"`
+	Sweeten     []string `json:"sweeten,omitempty"`
+	Fingerprint string   `json:"fingerprint"`
+	// InstanceSweetening is how an EC2 instance is to be sweetened
+	InstanceSweetening *aws.InstanceSweeteningConfig `json:"instanceSweetening,omitempty"`
+}
+
+// record returns the record of the entry skipped for the reason
+func (e PlanEntry) record(reason string) resourceRecord {
+	return resourceRecord{
+		Type:    e.Type,
+		ID:      e.ID,
+		Name:    e.Name,
+		Region:  e.Region,
+		Account: e.Account,
+		Action:  "delete",
+		Status:  string(stateSkipped),
+		Reason:  reason,
+	}
+}
+
+// plan collects the entries of clean --plan-out
+var plan = Plan{Entries: []PlanEntry{}}
+
+func addToPlan(t *target, list []aws.Deletable, sweeten bool) error {
+	for _, d := range list {
+		fingerprint, err := aws.Fingerprint(d)
+		if err != nil {
+			return err
+		}
+		entry := PlanEntry{
+			Type:        d.Type(),
+			ID:          d.ID(),
+			Name:        d.Name(),
+			Region:      t.Region,
+			Account:     t.Account,
+			Reason:      d.Reason(),
+			Fingerprint: fingerprint,
+		}
+		if sw, ok := d.(aws.Sweetener); ok && sweeten {
+			entry.Sweeten = sw.SweetenSteps()
+			if e, ok := d.(aws.EC2Instance); ok {
+				c := e.SweeteningConfig()
+				entry.InstanceSweetening = &c
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s [%s] planned for deletion: %s\n", d.Type(), d.Name(), d.Reason())
+		r := newRecord(t, d, "plan", "planned")
+		r.Reason = d.Reason()
+		addRecord(r)
+		plan.Entries = append(plan.Entries, entry)
+	}
+	return nil
+}
+
+func writePlan(path string) error {
+	plan.Created = time.Now().UTC()
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Couldn't encode plan: %s", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("Couldn't write plan: %s", err)
+	}
+	fmt.Fprintf(os.Stderr, "Plan with %d resources written to %s\n", len(plan.Entries), path)
+	return nil
+}
+
+func readPlan(path string) (*Plan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read plan: %s", err)
+	}
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("Couldn't parse plan [%s]: %s", path, err)
+	}
+	return &p, nil
+}
+
+func applyFunc(cmd *cobra.Command, args []string) {
+	if err := startRun(); err != nil {
+		log.Fatal(err)
+	}
+	p, err := readPlan(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Launch template versions are only listed on demand.
+	aws.Listing.LaunchTemplates = true
+	var retErr *multierror.Error
+	var kinds []aws.Kind
+	txs := make(map[string][]*transaction)
+	listed := make(map[string][]aws.Deletable)
+	for _, e := range p.Entries {
+		t, err := regionTarget(e.Account, e.Region)
+		if err != nil {
+			retErr = multierror.Append(retErr, err)
+			continue
+		}
+		d, err := aws.Lookup(t.Session, e.Type, e.ID)
+		if err != nil {
+			retErr = multierror.Append(retErr, err)
+			continue
+		}
+		if d == nil {
+			fmt.Fprintf(os.Stderr, "%s [%s] doesn't exist anymore, skipped\n", e.Type, e.Name)
+			addRecord(e.record("doesn't exist anymore"))
+			continue
+		}
+		fingerprint, err := aws.Fingerprint(d)
+		if err != nil {
+			retErr = multierror.Append(retErr, err)
+			continue
+		}
+		if fingerprint != e.Fingerprint {
+			fmt.Fprintf(os.Stderr, "%s [%s] changed since the plan was written, skipped\n", e.Type, e.Name)
+			addRecord(e.record("changed since the plan was written"))
+			continue
+		}
+		// What the resource depends on may have changed without changing
+		// the resource itself, such as a group used by a new instance.
+		byID, err := listedByKind(t, e.Type)
+		if err != nil {
+			retErr = multierror.Append(retErr, err)
+			continue
+		}
+		if d = byID[e.ID]; d == nil {
+			fmt.Fprintf(os.Stderr, "%s [%s] isn't to clean anymore, skipped\n", e.Type, e.Name)
+			addRecord(e.record("not listed for cleaning anymore"))
+			continue
+		}
+		if i, ok := d.(aws.EC2Instance); ok && e.InstanceSweetening != nil {
+			d = i.WithSweetening(*e.InstanceSweetening)
+		}
+		// Protection may have been added since the plan was written.
+		kept, err := excludeList(t, []aws.Deletable{d})
+		if err != nil {
+			retErr = multierror.Append(retErr, err)
+			continue
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		k, _ := aws.KindOfType(e.Type)
+		if _, ok := txs[k.Name]; !ok {
+			kinds = append(kinds, k)
+		}
+		txs[k.Name] = append(txs[k.Name], &transaction{
+			target:    t,
+			deletable: d,
+			sweeten:   len(e.Sweeten) > 0,
+			state:     statePending,
+		})
+		listed[k.Name] = append(listed[k.Name], d)
+	}
+	// Nothing is done unless the whole plan could be checked.
+	if err := retErr.ErrorOrNil(); err != nil {
+		log.Fatal(err)
+	}
+	// Kinds are applied as clean would, the referencing ones first, each
+	// waiting for the previous one to be gone.
+	ordered := aws.OrderKinds(kinds, listed)
+	for i, k := range ordered {
+		fmt.Fprintf(os.Stderr, "-- %s --\n", k.Name)
+		if err := runTransactions(txs[k.Name], k.Batch); err != nil {
+			retErr = multierror.Append(retErr, err)
+		}
+		if i == len(ordered)-1 {
+			continue
+		}
+		var targets []*target
+		deleted := make(map[*target][]aws.Deletable)
+		for _, tx := range txs[k.Name] {
+			if tx.state != stateDeleted {
+				continue
+			}
+			if _, ok := deleted[tx.target]; !ok {
+				targets = append(targets, tx.target)
+			}
+			deleted[tx.target] = append(deleted[tx.target], tx.deletable)
+		}
+		for _, t := range targets {
+			if err := waitDeleted(t, k, deleted[t]); err != nil {
+				retErr = multierror.Append(retErr, err)
+			}
+		}
+	}
+	printJournal()
+	if err := printRecords(); err != nil {
+		retErr = multierror.Append(retErr, err)
+	}
+	if err := retErr.ErrorOrNil(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// listings caches the resources listed by listedByKind, by target and kind
+var listings = make(map[string]map[string]aws.Deletable)
+
+// listedByKind returns the resources the kind of the given Type lists in
+// the target, by ID
+func listedByKind(t *target, typ string) (map[string]aws.Deletable, error) {
+	k, ok := aws.KindOfType(typ)
+	if !ok {
+		return nil, fmt.Errorf("Unknown resource type [%s]", typ)
+	}
+	key := t.Account + "/" + t.Region + "/" + k.Name
+	if listed, ok := listings[key]; ok {
+		return listed, nil
+	}
+	list, err := k.List(t.Session)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]aws.Deletable, len(list))
+	for _, d := range list {
+		listed[d.ID()] = d
+	}
+	listings[key] = listed
+	return listed, nil
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	addRunFlags(applyCmd.Flags())
+}
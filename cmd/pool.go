@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// concurrency is the number of resources processed at the same time,
+// see --concurrency
+var concurrency int
+
+// poolOutput is where runPool prints the output of the items
+var poolOutput io.Writer = os.Stderr
+
+// runPool calls fn for each of the n items, running up to concurrency of
+// them at the same time. What fn writes to out is printed to poolOutput
+// once the item is done, in the order of the items, so the output reads
+// the same as a sequential run. A sequential run writes straight to
+// poolOutput, progress showing as it goes. Errors are collected and
+// returned.
+func runPool(n int, fn func(i int, out io.Writer) error) error {
+	workers := concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	var retErr *multierror.Error
+	if workers == 1 {
+		for i := 0; i < n; i++ {
+			if err := fn(i, poolOutput); err != nil {
+				retErr = multierror.Append(retErr, err)
+			}
+		}
+		return retErr.ErrorOrNil()
+	}
+	outs := make([]bytes.Buffer, n)
+	errs := make([]error, n)
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	items := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range items {
+				errs[i] = fn(i, &outs[i])
+				close(done[i])
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < n; i++ {
+			items <- i
+		}
+		close(items)
+	}()
+	for i := 0; i < n; i++ {
+		<-done[i]
+		io.Copy(poolOutput, &outs[i])
+		if errs[i] != nil {
+			retErr = multierror.Append(retErr, errs[i])
+		}
+	}
+	wg.Wait()
+	return retErr.ErrorOrNil()
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+func TestRunPool(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("concurrency %d", workers), func(t *testing.T) {
+			var out bytes.Buffer
+			defer setPool(workers, &out)()
+			const n = 20
+			var calls [n]int32
+			err := runPool(n, func(i int, w io.Writer) error {
+				atomic.AddInt32(&calls[i], 1)
+				// The later items finish first.
+				time.Sleep(time.Duration(n-i) * time.Millisecond)
+				fmt.Fprintf(w, "item %d\n", i)
+				if i%7 == 3 {
+					return fmt.Errorf("item %d failed", i)
+				}
+				return nil
+			})
+
+			var want []string
+			for i := 0; i < n; i++ {
+				want = append(want, fmt.Sprintf("item %d", i))
+				if calls[i] != 1 {
+					t.Errorf("item %d called %d times, want 1", i, calls[i])
+				}
+			}
+			if got := strings.Split(strings.TrimSpace(out.String()), "\n"); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("output = %q, want the items in order", got)
+			}
+			merr, ok := err.(*multierror.Error)
+			if !ok {
+				t.Fatalf("err = %v, want a *multierror.Error", err)
+			}
+			var errs []string
+			for _, e := range merr.Errors {
+				errs = append(errs, e.Error())
+			}
+			if got, want := strings.Join(errs, ","), "item 3 failed,item 10 failed,item 17 failed"; got != want {
+				t.Errorf("errors = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRunPoolNoError(t *testing.T) {
+	defer setPool(3, &bytes.Buffer{})()
+	if err := runPool(5, func(i int, w io.Writer) error { return nil }); err != nil {
+		t.Errorf("err = %v, want nil", err)
+	}
+	if err := runPool(0, func(i int, w io.Writer) error { return fmt.Errorf("called") }); err != nil {
+		t.Errorf("err = %v, want nil without items", err)
+	}
+}
+
+// setPool sets the concurrency and output of runPool, until the returned
+// func restores them
+func setPool(workers int, out io.Writer) func() {
+	prevConcurrency, prevOutput := concurrency, poolOutput
+	concurrency, poolOutput = workers, out
+	return func() { concurrency, poolOutput = prevConcurrency, prevOutput }
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/spf13/cobra"
+
+	"github.com/Dal-Papa/awsugar/aws"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore [journal] [ids]",
+	Short: "Recreate resources deleted by clean or apply",
+	Long: `Recreate the resources recorded in a journal written by clean or apply,
+	all of them or only the given IDs.
+
+	Instances are launched from their AMI, volumes are created from their
+	snapshot, load balancers and network interfaces are created again with
+	their recorded configuration and Elastic IPs are allocated again, as
+	long as no other account took the address.
+	Resources deleted without recovery data are skipped.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  restoreFunc,
+}
+
+// Journal is the list of resources deleted by a run
+type Journal struct {
+	Created time.Time      `json:"created"`
+	Entries []JournalEntry `json:"entries"`
+}
+
+// JournalEntry is a deleted resource along with what is needed to
+// recreate it
+type JournalEntry struct {
+	Type     string        `json:"type"`
+	ID       string        `json:"id"`
+	Name     string        `json:"name"`
+	Region   string        `json:"region"`
+	Account  string        `json:"account"`
+	Deleted  time.Time     `json:"deleted"`
+	Recovery *aws.Recovery `json:"recovery,omitempty"`
+}
+
+// record returns the record of the restoration of the entry
+func (e JournalEntry) record(status string) resourceRecord {
+	r := resourceRecord{
+		Type:    e.Type,
+		ID:      e.ID,
+		Name:    e.Name,
+		Region:  e.Region,
+		Account: e.Account,
+		Action:  "restore",
+		Status:  status,
+	}
+	if e.Recovery != nil {
+		r.Artifact = e.Recovery.Artifact()
+	}
+	return r
+}
+
+// journal collects the resources deleted by the run
+var journal = Journal{Entries: []JournalEntry{}}
+
+// journalMu guards journal, resources being deleted concurrently
+var journalMu sync.Mutex
+
+// journalPath is where the journal is written, see --journal
+var journalPath string
+
+// addToJournal adds the deleted resource to the journal and writes it
+// right away, so a run killed midway still leaves the journal of what it
+// deleted. The journal is created with the first entry.
+func addToJournal(t *target, d aws.Deletable, recovery *aws.Recovery) error {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	now := time.Now().UTC()
+	if len(journal.Entries) == 0 {
+		journal.Created = now
+		if journalPath == "" {
+			journalPath = fmt.Sprintf("awsugar-journal-%s.json", journal.Created.Format("20060102-150405"))
+		}
+	}
+	journal.Entries = append(journal.Entries, JournalEntry{
+		Type:     d.Type(),
+		ID:       d.ID(),
+		Name:     d.Name(),
+		Region:   t.Region,
+		Account:  t.Account,
+		Deleted:  now,
+		Recovery: recovery,
+	})
+	return saveJournal()
+}
+
+// saveJournal replaces the journal file with the journal, through a
+// temporary file so that it's never left half written
+func saveJournal() error {
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Couldn't encode journal: %s", err)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(journalPath), "."+filepath.Base(journalPath))
+	if err != nil {
+		return fmt.Errorf("Couldn't write journal: %s", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), journalPath)
+	}
+	if err != nil {
+		return fmt.Errorf("Couldn't write journal [%s]: %s", journalPath, err)
+	}
+	return nil
+}
+
+// printJournal tells where the journal was written, unless nothing was
+// deleted
+func printJournal() {
+	if len(journal.Entries) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Journal of %d deleted resources written to %s\n", len(journal.Entries), journalPath)
+}
+
+func readJournal(path string) (*Journal, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read journal: %s", err)
+	}
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("Couldn't parse journal [%s]: %s", path, err)
+	}
+	return &j, nil
+}
+
+func restoreFunc(cmd *cobra.Command, args []string) {
+	j, err := readJournal(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	ids := make(map[string]bool, len(args)-1)
+	for _, id := range args[1:] {
+		ids[id] = true
+	}
+	var retErr *multierror.Error
+	for _, e := range j.Entries {
+		if len(ids) > 0 && !ids[e.ID] {
+			continue
+		}
+		if e.Recovery == nil {
+			fmt.Fprintf(os.Stderr, "%s [%s] has no recovery data, skipped\n", e.Type, e.Name)
+			r := e.record(string(stateSkipped))
+			r.Reason = "no recovery data"
+			addRecord(r)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s [%s] to be restored...\n", e.Type, e.Name)
+		if rootFlags.DryRun {
+			addRecord(e.record(string(stateDryRun)))
+			continue
+		}
+		t, err := regionTarget(e.Account, e.Region)
+		if err != nil {
+			retErr = multierror.Append(retErr, err)
+			continue
+		}
+		id, err := e.Recovery.Restore(t.Session, e.Name)
+		if err != nil {
+			r := e.record(string(stateFailed))
+			r.Error = err.Error()
+			addRecord(r)
+			retErr = multierror.Append(retErr, err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s [%s] restored as %s\n", e.Type, e.Name, id)
+		r := e.record("restored")
+		r.Reason = "restored as " + id
+		addRecord(r)
+	}
+	if err := printRecords(); err != nil {
+		retErr = multierror.Append(retErr, err)
+	}
+	if err := retErr.ErrorOrNil(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
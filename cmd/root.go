@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/spf13/cobra"
+
+	awsugar "github.com/Dal-Papa/awsugar/aws"
 )
 
 var sess *session.Session
@@ -20,32 +26,113 @@ var rootCmd = &cobra.Command{
 }
 
 var rootFlags struct {
-	DryRun bool
-	Region string
+	DryRun       bool
+	Regions      []string
+	Accounts     []string
+	AccountsFile string
+	RoleName     string
+	ExternalID   string
+	SessionName  string
+	Profile      string
+	RoleARN      string
+	MFASerial    string
+	EndpointURLs []string
+	Output       string
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
+const defaultRegion = "us-west-2"
+
 func initSession() {
+	if err := checkRegions(); err != nil {
+		log.Fatal(err)
+	}
+	region := rootFlags.Regions[0]
+	if region == "all" {
+		region = defaultRegion
+	}
+	config := aws.Config{
+		Region: aws.String(region),
+	}
+	if len(rootFlags.EndpointURLs) > 0 {
+		config.EndpointResolver = awsugar.EndpointResolver(endpointOverrides())
+	}
 	sess = session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-		Config: aws.Config{
-			Region: aws.String(rootFlags.Region),
-		},
+		Profile:                 rootFlags.Profile,
+		SharedConfigState:       session.SharedConfigEnable,
+		Config:                  config,
+		AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
 	}))
+	if rootFlags.RoleARN != "" {
+		sess = awsugar.AssumeRole(sess, rootFlags.RoleARN, awsugar.RoleOptions{
+			ExternalID:  rootFlags.ExternalID,
+			SessionName: rootFlags.SessionName,
+			MFASerial:   rootFlags.MFASerial,
+		})
+	}
+}
+
+// checkRegions checks --region holds at least one region and no empty one,
+// as given by --region= or an empty AWSUGAR_REGION
+func checkRegions() error {
+	if len(rootFlags.Regions) == 0 {
+		return errors.New("At least one region is required, see --region")
+	}
+	for _, r := range rootFlags.Regions {
+		if strings.TrimSpace(r) == "" {
+			return fmt.Errorf("Invalid regions [%s], empty region", strings.Join(rootFlags.Regions, ","))
+		}
+	}
+	return nil
+}
+
+// endpointOverrides parses the --endpoint-url values, either service=url
+// or a bare url used for every service
+func endpointOverrides() map[string]string {
+	overrides := make(map[string]string, len(rootFlags.EndpointURLs))
+	for _, e := range rootFlags.EndpointURLs {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) == 2 {
+			overrides[parts[0]] = parts[1]
+		} else {
+			overrides["*"] = e
+		}
+	}
+	return overrides
 }
 
 func init() {
-	cobra.OnInitialize(initSession)
+	cobra.OnInitialize(initConfig, initOutput, initSession)
 	rootCmd.PersistentFlags().BoolVarP(&rootFlags.DryRun, "dry-run", "d", false,
 		"Toggle a list-only mode without executing any action.")
-	rootCmd.PersistentFlags().StringVarP(&rootFlags.Region, "region", "r", "us-west-2",
-		"Choose the region to execute the actions in")
+	rootCmd.PersistentFlags().StringSliceVarP(&rootFlags.Regions, "region", "r", []string{defaultRegion},
+		"Choose the regions to execute the actions in, comma-separated or all")
+	rootCmd.PersistentFlags().StringSliceVar(&rootFlags.Accounts, "accounts", []string{},
+		"IDs of the accounts to execute the actions in by assuming --role-name")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.AccountsFile, "accounts-file", "",
+		"JSON file mapping the IDs of the accounts to execute the actions in to the role ARN to assume")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.RoleName, "role-name", "OrganizationAccountAccessRole",
+		"Name of the role to assume in the accounts given through --accounts")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.ExternalID, "external-id", "",
+		"External ID to provide when assuming roles")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.SessionName, "session-name", "awsugar",
+		"Session name to use when assuming roles")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.Profile, "profile", "",
+		"Use a specific profile from the shared credentials and config files")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.RoleARN, "role-arn", "",
+		"ARN of a role to assume before executing the actions")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.MFASerial, "mfa-serial", "",
+		"Serial number of the MFA device to prompt a token for when assuming --role-arn")
+	rootCmd.PersistentFlags().StringSliceVar(&rootFlags.EndpointURLs, "endpoint-url", []string{},
+		"Override the endpoint of every service with url, or of a single one with service=url")
+	rootCmd.PersistentFlags().StringVarP(&rootFlags.Output, "output", "o", "table",
+		"Format of the results printed to stdout: table, json, yaml or csv")
 }
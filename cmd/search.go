@@ -1,20 +1,27 @@
 package cmd
 
 import (
+	"fmt"
+	"log"
 	"net"
 
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
+
+	"github.com/Dal-Papa/awsugar/aws"
 )
 
 // searchCmd represents the search command
 var searchCmd = &cobra.Command{
-	Use:   "search [type]",
+	Use:   "search",
 	Short: "Search through various AWS services",
 	Long: `Provides some helpers to search through services in AWS.
 	
-	Allows to search for an IP in Route53.`,
-	Args: cobra.MinimumNArgs(1),
-	Run:  searchFunc,
+	Allows to find what owns an IP among Route53 records, network interfaces,
+	Elastic IPs, EC2 instances and load balancers.
+	Allows to find instances, volumes, snapshots, network interfaces, load
+	balancers and security groups by tag (key=value, key=val* or key).`,
+	Run: searchFunc,
 }
 
 var searchFlags struct {
@@ -23,14 +30,99 @@ var searchFlags struct {
 }
 
 func searchFunc(cmd *cobra.Command, args []string) {
-	if len(searchFlags.IP) < 1 {
-		rootCmd.Usage()
+	if len(searchFlags.IP) < 1 && len(searchFlags.Tags) < 1 {
+		cmd.Usage()
 		return
 	}
+	var retErr *multierror.Error
+	if len(searchFlags.IP) > 0 {
+		if err := searchRoute53(); err != nil {
+			retErr = multierror.Append(retErr, err)
+		}
+		if err := forEachTarget(searchIPs); err != nil {
+			retErr = multierror.Append(retErr, err)
+		}
+	}
+	if len(searchFlags.Tags) > 0 {
+		filters := make([]aws.TagFilter, 0, len(searchFlags.Tags))
+		for _, t := range searchFlags.Tags {
+			f, err := aws.ParseTagFilter(t)
+			if err != nil {
+				log.Fatal(err)
+			}
+			filters = append(filters, f)
+		}
+		if err := forEachTarget(func(t *target) error {
+			return searchTags(t, filters)
+		}); err != nil {
+			retErr = multierror.Append(retErr, err)
+		}
+	}
+	if err := printRecords(); err != nil {
+		retErr = multierror.Append(retErr, err)
+	}
+	if err := retErr.ErrorOrNil(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func init() {
 	rootCmd.AddCommand(searchCmd)
 
 	searchCmd.Flags().IPSliceVarP(&searchFlags.IP, "ip", "", []net.IP{}, "list of IPs to search")
+	searchCmd.Flags().StringArrayVar(&searchFlags.Tags, "tag", []string{},
+		"tag to search for as key=value, key=val* or key (can be repeated)")
+}
+
+// searchRoute53 searches the hosted zones of every target account. Route53
+// is global, each account is searched once rather than in every region.
+func searchRoute53() error {
+	list, err := targetAccounts()
+	if err != nil {
+		return err
+	}
+	var retErr *multierror.Error
+	for _, a := range list {
+		res, err := aws.SearchRoute53(a.Session, searchFlags.IP)
+		if err != nil {
+			retErr = multierror.Append(retErr, fmt.Errorf("[%s] %s", a.ID, err))
+		}
+		addIPOwners(&target{Account: a.ID, Region: "global"}, res)
+	}
+	return retErr.ErrorOrNil()
+}
+
+func searchIPs(t *target) error {
+	res, err := aws.SearchIPs(t.Session, searchFlags.IP)
+	addIPOwners(t, res)
+	return err
+}
+
+func addIPOwners(t *target, list []aws.IPOwner) {
+	for _, o := range list {
+		addRecord(ipRecord{
+			IP:         o.IP,
+			Type:       o.Type,
+			ID:         o.ID,
+			Name:       o.Name,
+			HostedZone: o.HostedZone,
+			Region:     t.Region,
+			Account:    t.Account,
+		})
+	}
+}
+
+func searchTags(t *target, filters []aws.TagFilter) error {
+	res, err := aws.SearchTags(t.Session, filters)
+	for _, r := range res {
+		addRecord(tagRecord{
+			Type:    r.Type,
+			ID:      r.ID,
+			Name:    r.Name,
+			Region:  t.Region,
+			Account: t.Account,
+			Tags:    r.Tags,
+		})
+	}
+	return err
 }
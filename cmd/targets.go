@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	multierror "github.com/hashicorp/go-multierror"
+
+	"github.com/Dal-Papa/awsugar/aws"
+)
+
+// account is an account commands run against, with the session
+// authenticated into it
+type account struct {
+	ID      string
+	Session *session.Session
+	regions []string
+}
+
+// accounts are the accounts every command runs in, resolved from the
+// account flags by targetAccounts
+var accounts []*account
+
+// targetAccounts returns the accounts given through --accounts and
+// --accounts-file, each assuming its role. Without any, the credentials
+// of the base session are used as is.
+func targetAccounts() ([]*account, error) {
+	if accounts != nil {
+		return accounts, nil
+	}
+	roles := make(map[string]string)
+	if rootFlags.AccountsFile != "" {
+		data, err := ioutil.ReadFile(rootFlags.AccountsFile)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't read accounts file: %s", err)
+		}
+		if err := json.Unmarshal(data, &roles); err != nil {
+			return nil, fmt.Errorf("Couldn't parse accounts file [%s]: %s",
+				rootFlags.AccountsFile, err)
+		}
+	}
+	for _, id := range rootFlags.Accounts {
+		if _, ok := roles[id]; !ok {
+			roles[id] = fmt.Sprintf("arn:aws:iam::%s:role/%s", id, rootFlags.RoleName)
+		}
+	}
+	if len(roles) == 0 {
+		id, err := aws.AccountID(sess)
+		if err != nil {
+			return nil, err
+		}
+		accounts = []*account{{ID: id, Session: sess}}
+		return accounts, nil
+	}
+	ids := make([]string, 0, len(roles))
+	for id := range roles {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		accounts = append(accounts, &account{
+			ID: id,
+			Session: aws.AssumeRole(sess, roles[id], aws.RoleOptions{
+				ExternalID:  rootFlags.ExternalID,
+				SessionName: rootFlags.SessionName,
+			}),
+		})
+	}
+	return accounts, nil
+}
+
+// targetRegions returns the regions of the account given through
+// --region, "all" being every region enabled for the account
+func (a *account) targetRegions() ([]string, error) {
+	if a.regions != nil {
+		return a.regions, nil
+	}
+	for _, r := range rootFlags.Regions {
+		if r == "all" {
+			all, err := aws.ListRegions(a.Session)
+			if err != nil {
+				return nil, err
+			}
+			a.regions = all
+			return a.regions, nil
+		}
+	}
+	a.regions = rootFlags.Regions
+	return a.regions, nil
+}
+
+// target is a region of an account commands run against
+type target struct {
+	Account string
+	Region  string
+	Session *session.Session
+}
+
+// forEachTarget runs fn with a session for every target region of every
+// target account. The output of each is grouped under the account and
+// region it belongs to and a failing one doesn't prevent the others from
+// running, errors are collected and returned.
+func forEachTarget(fn func(t *target) error) error {
+	list, err := targetAccounts()
+	if err != nil {
+		return err
+	}
+	var retErr *multierror.Error
+	for _, a := range list {
+		regions, err := a.targetRegions()
+		if err != nil {
+			retErr = multierror.Append(retErr, fmt.Errorf("[%s] %s", a.ID, err))
+			continue
+		}
+		for _, r := range regions {
+			fmt.Fprintf(os.Stderr, "== %s / %s ==\n", a.ID, r)
+			if err := fn(&target{
+				Account: a.ID,
+				Region:  r,
+				Session: a.Session.Copy(&awssdk.Config{Region: awssdk.String(r)}),
+			}); err != nil {
+				retErr = multierror.Append(retErr, fmt.Errorf("[%s/%s] %s", a.ID, r, err))
+			}
+		}
+	}
+	return retErr.ErrorOrNil()
+}
+
+// accountSession returns the session of the account, among the target
+// accounts or by assuming --role-name in it
+func accountSession(id string) (*session.Session, error) {
+	list, err := targetAccounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range list {
+		if a.ID == id {
+			return a.Session, nil
+		}
+	}
+	return aws.AssumeRole(sess, fmt.Sprintf("arn:aws:iam::%s:role/%s", id, rootFlags.RoleName),
+		aws.RoleOptions{
+			ExternalID:  rootFlags.ExternalID,
+			SessionName: rootFlags.SessionName,
+		}), nil
+}
+
+// regionTargets caches the targets built by regionTarget
+var regionTargets = make(map[string]*target)
+
+// regionTarget returns the target for a region of an account, the account
+// being among the target accounts or reached by assuming --role-name
+func regionTarget(accountID, region string) (*target, error) {
+	key := accountID + "/" + region
+	if t, ok := regionTargets[key]; ok {
+		return t, nil
+	}
+	s, err := accountSession(accountID)
+	if err != nil {
+		return nil, err
+	}
+	t := &target{
+		Account: accountID,
+		Region:  region,
+		Session: s.Copy(&awssdk.Config{Region: awssdk.String(region)}),
+	}
+	regionTargets[key] = t
+	return t, nil
+}
@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/Dal-Papa/awsugar/aws"
+)
+
+// sweetenTimeout bounds the sweetening of each resource, see
+// --sweeten-timeout
+var sweetenTimeout time.Duration
+
+// exclusionFlags are the flags building exclusion
+var exclusionFlags struct {
+	ProtectTag string
+	Tags       []string
+	IDs        []string
+}
+
+// exclusion keeps resources out of the run, see excludeList
+var exclusion aws.Exclusion
+
+// minAge is the age under which resources are kept out of the run, see
+// excludeList
+var minAge time.Duration
+
+// runCtx is canceled on the first interrupt of a clean or apply run, the
+// sweetening in progress stops waiting and nothing more gets deleted
+var runCtx = context.Background()
+
+// addRunFlags adds the flags of the commands running transactions
+func addRunFlags(fs *pflag.FlagSet) {
+	fs.IntVar(&concurrency, "concurrency", 1,
+		"number of resources to sweeten and delete at the same time")
+	fs.StringVar(&journalPath, "journal", "",
+		"file to write the journal of deleted resources to, for awsugar restore (default awsugar-journal-<time>.json)")
+	fs.DurationVar(&sweetenTimeout, "sweeten-timeout", 2*time.Hour,
+		"maximum time to sweeten a resource or to wait for terminated instances to be gone, 0 to wait as long as needed")
+	fs.StringVar(&exclusionFlags.ProtectTag, "protect-tag", "awsugar:protect=true",
+		"never clean resources with this tag, key=value, key=val* or key (empty to disable)")
+	fs.StringArrayVar(&exclusionFlags.Tags, "exclude-tag", []string{},
+		"don't clean resources with the tag key=value, key=val* or key (can be repeated)")
+	fs.StringSliceVar(&exclusionFlags.IDs, "exclude-ids", []string{},
+		"IDs of resources not to clean")
+	fs.BoolVar(&aws.InstanceSweetening.Snapshots, "snapshot-volumes", false,
+		"sweeten EC2 instances by snapshotting each volume instead of creating an AMI")
+	fs.BoolVar(&aws.InstanceSweetening.NoReboot, "no-reboot", false,
+		"create the AMI of EC2 instances without rebooting them first")
+	fs.DurationVar(&aws.Waiting.Delay, "wait-delay", aws.Waiting.Delay,
+		"delay between two checks of the state of a snapshot, AMI or terminated instance")
+	fs.IntVar(&aws.Waiting.MaxAttempts, "wait-max-attempts", 0,
+		"maximum number of checks of the state of a snapshot, AMI or terminated instance, 0 for no limit")
+	fs.StringVar(&aws.Exporting.Dir, "export-dir", aws.Exporting.Dir,
+		"directory to export load balancers and target groups to before deleting them")
+	fs.BoolVarP(&confirmFlags.Interactive, "interactive", "i", false,
+		"show the details of each resource and ask whether to delete it")
+	fs.BoolVarP(&confirmFlags.Yes, "yes", "y", false,
+		"don't ask to confirm the deletions")
+}
+
+// startRun prepares a run of transactions once the flags are parsed
+func startRun() error {
+	exclusion = aws.Exclusion{IDs: exclusionFlags.IDs}
+	if exclusionFlags.ProtectTag != "" {
+		f, err := aws.ParseTagFilter(exclusionFlags.ProtectTag)
+		if err != nil {
+			return err
+		}
+		exclusion.Protection = f
+	}
+	for _, t := range exclusionFlags.Tags {
+		f, err := aws.ParseTagFilter(t)
+		if err != nil {
+			return err
+		}
+		exclusion.Tags = append(exclusion.Tags, f)
+	}
+	aws.Waiting.ShowProgress = concurrency <= 1
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		// A second interrupt kills the process as usual.
+		signal.Stop(interrupt)
+		fmt.Fprintln(os.Stderr, "\nInterrupted, stopping...")
+		cancel()
+	}()
+	runCtx = ctx
+	return nil
+}
+
+// excludeList drops the resources excluded from the run and those younger
+// than minAge, telling why
+func excludeList(t *target, list []aws.Deletable) ([]aws.Deletable, error) {
+	kept, excluded, err := exclusion.Filter(t.Session, list)
+	if err != nil {
+		return nil, err
+	}
+	if minAge > 0 {
+		var young []aws.Excluded
+		if kept, young, err = aws.FilterOlderThan(t.Session, kept, minAge); err != nil {
+			return nil, err
+		}
+		excluded = append(excluded, young...)
+	}
+	// What the excluded resources reference, such as the groups in the
+	// rules of a protected group, can't be deleted either.
+	kept, excluded = aws.FilterReferenced(kept, excluded)
+	for _, x := range excluded {
+		fmt.Fprintf(os.Stderr, "%s [%s] skipped: %s\n", x.Type(), x.Name(), x.Why)
+		r := newRecord(t, x, "delete", string(stateSkipped))
+		r.Reason = x.Why
+		addRecord(r)
+	}
+	return kept, nil
+}
+
+// itemState is how far the clean transaction of a resource went
+type itemState string
+
+const (
+	statePending   itemState = "pending"
+	stateSweetened itemState = "sweetened"
+	stateDeleted   itemState = "deleted"
+	stateSkipped   itemState = "skipped"
+	stateDryRun    itemState = "dry-run"
+	stateFailed    itemState = "failed"
+)
+
+// transaction is the Sweeten→Delete of a single resource. A resource is
+// never deleted once its own sweetening failed.
+type transaction struct {
+	target    *target
+	deletable aws.Deletable
+	sweeten   bool
+	state     itemState
+	err       error
+	// artifact is what Sweeten left to restore the resource from, such as
+	// an AMI, snapshots or an export file
+	artifact string
+	// reason explains why the transaction was skipped
+	reason string
+}
+
+func newTransactions(t *target, list []aws.Deletable, sweeten bool) []*transaction {
+	txs := make([]*transaction, len(list))
+	for i, d := range list {
+		txs[i] = &transaction{target: t, deletable: d, sweeten: sweeten, state: statePending}
+	}
+	return txs
+}
+
+// waitContext returns the context of a wait of the run, done once
+// --sweeten-timeout is over or on interrupt
+func waitContext() (context.Context, context.CancelFunc) {
+	if sweetenTimeout > 0 {
+		return context.WithTimeout(runCtx, sweetenTimeout)
+	}
+	return context.WithCancel(runCtx)
+}
+
+// runSweeten sweetens the resource if it's a Sweetener and sweetening
+// was asked for
+func (tx *transaction) runSweeten(out io.Writer) {
+	sw, ok := tx.deletable.(aws.Sweetener)
+	if !ok || !tx.sweeten || rootFlags.DryRun || tx.state == stateSkipped {
+		return
+	}
+	if tx.interrupted(out) {
+		return
+	}
+	ctx, cancel := waitContext()
+	defer cancel()
+	artifact, err := sw.Sweeten(ctx, tx.target.Session, out)
+	if err != nil {
+		tx.fail(out, fmt.Errorf("%s [%s] not deleted, sweetening failed: %s",
+			tx.deletable.Type(), tx.deletable.Name(), err))
+		return
+	}
+	tx.artifact = artifact
+	tx.state = stateSweetened
+}
+
+// runDelete deletes the resource and records it in the journal, unless
+// the transaction already failed. A resource whose recovery can't be
+// recorded isn't deleted.
+func (tx *transaction) runDelete(out io.Writer) {
+	if tx.state == stateFailed || tx.state == stateSkipped || tx.interrupted(out) {
+		return
+	}
+	d := tx.deletable
+	fmt.Fprintf(out, "%s [%s] to be deleted...\n", d.Type(), d.Name())
+	if rootFlags.DryRun {
+		tx.state = stateDryRun
+		return
+	}
+	var recovery *aws.Recovery
+	if r, ok := d.(aws.Recoverable); ok {
+		var err error
+		if recovery, err = r.Recovery(tx.target.Session, tx.artifact); err != nil {
+			tx.fail(out, err)
+			return
+		}
+		if recovery != nil && recovery.Artifact() != "" {
+			tx.artifact = recovery.Artifact()
+		}
+	}
+	if err := d.Delete(tx.target.Session); err != nil {
+		tx.fail(out, err)
+		return
+	}
+	tx.state = stateDeleted
+	fmt.Fprintf(out, "%s [%s] deleted successfully!\n", d.Type(), d.Name())
+	if err := addToJournal(tx.target, d, recovery); err != nil {
+		fmt.Fprintf(out, "%s [%s] missing from the journal!\n", d.Type(), d.Name())
+		tx.err = err
+	}
+}
+
+// interrupted fails the transaction if the run was interrupted
+func (tx *transaction) interrupted(out io.Writer) bool {
+	if runCtx.Err() == nil {
+		return false
+	}
+	tx.fail(out, fmt.Errorf("%s [%s] not deleted, interrupted", tx.deletable.Type(), tx.deletable.Name()))
+	return true
+}
+
+func (tx *transaction) fail(out io.Writer, err error) {
+	fmt.Fprintf(out, "%s [%s] failed!\n", tx.deletable.Type(), tx.deletable.Name())
+	tx.state = stateFailed
+	tx.err = err
+}
+
+// runTransactions runs the transactions confirmed by confirm through the
+// pool. In batch mode every resource is sweetened before the first
+// deletion, for resources whose sweetening unblocks the deletion of the
+// others. Errors of the failed transactions are collected and returned.
+func runTransactions(txs []*transaction, batch bool) error {
+	confirm(txs)
+	var err error
+	if batch {
+		runPool(len(txs), func(i int, out io.Writer) error {
+			txs[i].runSweeten(out)
+			return nil
+		})
+		err = runPool(len(txs), func(i int, out io.Writer) error {
+			txs[i].runDelete(out)
+			return txs[i].err
+		})
+	} else {
+		err = runPool(len(txs), func(i int, out io.Writer) error {
+			txs[i].runSweeten(out)
+			txs[i].runDelete(out)
+			return txs[i].err
+		})
+	}
+	for _, tx := range txs {
+		addRecord(tx.record())
+	}
+	printSummary(txs)
+	return err
+}
+
+// record returns the outcome of the transaction
+func (tx *transaction) record() resourceRecord {
+	r := newRecord(tx.target, tx.deletable, "delete", string(tx.state))
+	r.Reason = tx.deletable.Reason()
+	if tx.reason != "" {
+		r.Reason = tx.reason
+	}
+	r.Artifact = tx.artifact
+	if tx.err != nil {
+		r.Error = tx.err.Error()
+	}
+	return r
+}
+
+// printSummary prints how many transactions ended in each state
+func printSummary(txs []*transaction) {
+	if len(txs) == 0 {
+		return
+	}
+	counts := make(map[itemState]int)
+	for _, tx := range txs {
+		counts[tx.state]++
+	}
+	var parts []string
+	for _, s := range []itemState{statePending, stateSweetened, stateDeleted, stateSkipped, stateDryRun, stateFailed} {
+		if counts[s] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[s], s))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Summary: %s\n", strings.Join(parts, ", "))
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	multierror "github.com/hashicorp/go-multierror"
+
+	awsugar "github.com/Dal-Papa/awsugar/aws"
+)
+
+// callLog records the calls made to fake resources, in order
+type callLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *callLog) add(call string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, call)
+}
+
+// fakeDeletable is a resource whose sweetening and deletion fail as told
+type fakeDeletable struct {
+	id         string
+	sweetenErr error
+	deleteErr  error
+	log        *callLog
+}
+
+var _ = awsugar.Deletable(&fakeDeletable{})
+var _ = awsugar.Sweetener(&fakeDeletable{})
+
+func (f fakeDeletable) Type() string   { return "Fake" }
+func (f fakeDeletable) ID() string     { return f.id }
+func (f fakeDeletable) Name() string   { return f.id }
+func (f fakeDeletable) Reason() string { return "fake" }
+
+func (f fakeDeletable) SweetenSteps() []string { return []string{"sweeten " + f.id} }
+
+func (f fakeDeletable) Sweeten(ctx aws.Context, s *session.Session, out io.Writer) (string, error) {
+	f.log.add("sweeten " + f.id)
+	fmt.Fprintf(out, "sweetening %s\n", f.id)
+	if f.sweetenErr != nil {
+		return "", f.sweetenErr
+	}
+	return "artifact-" + f.id, nil
+}
+
+func (f fakeDeletable) Delete(s *session.Session) error {
+	f.log.add("delete " + f.id)
+	return f.deleteErr
+}
+
+func TestRunTransactions(t *testing.T) {
+	errSweeten := errors.New("sweeten failed")
+	errDelete := errors.New("delete failed")
+	for _, tc := range []struct {
+		name    string
+		batch   bool
+		sweeten bool
+		fails   map[string][2]error
+		states  map[string]itemState
+		errs    int
+	}{
+		{
+			name:    "sweeten then delete",
+			sweeten: true,
+			states:  map[string]itemState{"a": stateDeleted, "b": stateDeleted, "c": stateDeleted, "d": stateDeleted},
+		},
+		{
+			name:    "no delete after failed sweeten",
+			sweeten: true,
+			fails:   map[string][2]error{"b": {errSweeten, nil}},
+			states:  map[string]itemState{"a": stateDeleted, "b": stateFailed, "c": stateDeleted, "d": stateDeleted},
+			errs:    1,
+		},
+		{
+			name:    "no delete after failed sweeten in batch",
+			batch:   true,
+			sweeten: true,
+			fails:   map[string][2]error{"a": {errSweeten, nil}, "d": {errSweeten, nil}},
+			states:  map[string]itemState{"a": stateFailed, "b": stateDeleted, "c": stateDeleted, "d": stateFailed},
+			errs:    2,
+		},
+		{
+			name:   "errors aggregated",
+			fails:  map[string][2]error{"a": {nil, errDelete}, "c": {nil, errDelete}},
+			states: map[string]itemState{"a": stateFailed, "b": stateDeleted, "c": stateFailed, "d": stateDeleted},
+			errs:   2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setTransactions(t)()
+			log := &callLog{}
+			var list []awsugar.Deletable
+			for _, id := range []string{"a", "b", "c", "d"} {
+				list = append(list, fakeDeletable{id, tc.fails[id][0], tc.fails[id][1], log})
+			}
+			txs := newTransactions(&target{Account: "123456789012", Region: "us-east-1"}, list, tc.sweeten)
+			runErr := runTransactions(txs, tc.batch)
+
+			var deletedCount int
+			for _, tx := range txs {
+				if want := tc.states[tx.deletable.ID()]; tx.state != want {
+					t.Errorf("%s state = %s, want %s", tx.deletable.ID(), tx.state, want)
+				}
+				if tx.state == stateDeleted {
+					deletedCount++
+				}
+				if want := "artifact-" + tx.deletable.ID(); tc.sweeten && tx.state == stateDeleted && tx.artifact != want {
+					t.Errorf("%s artifact = %q, want %q", tx.deletable.ID(), tx.artifact, want)
+				}
+			}
+			j, err := readJournal(journalPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(j.Entries) != deletedCount {
+				t.Errorf("journal has %d entries, want %d", len(j.Entries), deletedCount)
+			}
+			var errs []error
+			if merr, ok := runErr.(*multierror.Error); ok {
+				errs = merr.Errors
+			} else if runErr != nil {
+				t.Fatalf("err = %v, want a *multierror.Error", runErr)
+			}
+			if len(errs) != tc.errs {
+				t.Errorf("%d errors, want %d: %v", len(errs), tc.errs, runErr)
+			}
+
+			sweetened, deleted := make(map[string]bool), make(map[string]bool)
+			lastSweeten, firstDelete := -1, len(log.calls)
+			for i, call := range log.calls {
+				parts := strings.Fields(call)
+				switch parts[0] {
+				case "sweeten":
+					if deleted[parts[1]] {
+						t.Errorf("%s sweetened after its deletion", parts[1])
+					}
+					sweetened[parts[1]] = true
+					lastSweeten = i
+				case "delete":
+					if tc.sweeten && !sweetened[parts[1]] {
+						t.Errorf("%s deleted before being sweetened", parts[1])
+					}
+					deleted[parts[1]] = true
+					if i < firstDelete {
+						firstDelete = i
+					}
+				}
+			}
+			for id, fail := range tc.fails {
+				if fail[0] != nil && deleted[id] {
+					t.Errorf("%s deleted after its sweetening failed", id)
+				}
+			}
+			if !tc.sweeten && len(sweetened) > 0 {
+				t.Errorf("sweetened %v without sweetening", sweetened)
+			}
+			if tc.batch && lastSweeten > firstDelete {
+				t.Errorf("calls = %v, want every sweetening before the first deletion", log.calls)
+			}
+		})
+	}
+}
+
+// setTransactions sets up the globals of a run for the test, until the
+// returned func restores them
+func setTransactions(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "awsugar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restorePool := setPool(3, ioutil.Discard)
+	prevYes, prevJournalPath := confirmFlags.Yes, journalPath
+	confirmFlags.Yes = true
+	journalPath = filepath.Join(dir, "journal.json")
+	return func() {
+		restorePool()
+		confirmFlags.Yes, journalPath = prevYes, prevJournalPath
+		journal = Journal{Entries: []JournalEntry{}}
+		records = []tabular{}
+		os.RemoveAll(dir)
+	}
+}